@@ -0,0 +1,112 @@
+// Package client provides helpers to call services which respond with a wrapped.Response.
+//
+//	// Example
+//
+//	c := &http.Client{Transport: client.NewRetryTransport(nil)}
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//
+//	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/api/demo", nil)
+//	resp, err := c.Do(req)
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// MaxWrappedSize is the maximum body size in bytes which will be decoded by ParseWrapped.
+var MaxWrappedSize int64 = 1 << 20
+
+// ParseWrapped will decode the body of resp into a wrapped.Response.
+//
+// The body will be restored, so it can be read again by the caller. If the body
+// is not json, exceeds MaxWrappedSize or can not be decoded, nil will be returned.
+func ParseWrapped(resp *http.Response) *wrapped.Response {
+	if resp == nil || resp.Body == nil || !isJSON(resp.Header.Get("Content-Type")) {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxWrappedSize+1))
+	if err != nil || int64(len(body)) > MaxWrappedSize {
+		// restore the read part in front of the remaining body
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return nil
+	}
+	_ = resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var wrap wrapped.Response
+	if err := json.Unmarshal(body, &wrap); err != nil || wrap.Status == "" {
+		return nil
+	}
+
+	return &wrap
+}
+
+// RetryAfter will parse the Retry-After header of resp.
+//
+// The header can either contain delay-seconds or a HTTP-date. The second return
+// value will be false if the header is missing or invalid.
+func RetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if d := date.Sub(now); d > 0 {
+		return d, true
+	}
+
+	return 0, true
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// drain reads up to MaxWrappedSize of body, so the connection can be reused, and closes it.
+// Larger bodies are not read completely.
+func drain(body io.ReadCloser) {
+	if body == nil {
+		return
+	}
+	_, _ = io.CopyN(ioutil.Discard, body, MaxWrappedSize)
+	_ = body.Close()
+}
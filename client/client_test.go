@@ -0,0 +1,110 @@
+package client_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/client"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+func TestParseWrapped(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	rr := httptest.NewRecorder()
+	rest.BadRequest(rr, req, "msg")
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+
+	wrap := client.ParseWrapped(resp)
+	if wrap == nil {
+		t.Fatal(`expected wrapped.Response`)
+	}
+	if wrap.Status != wrapped.StatusError {
+		t.Fatalf(`expected Status to be '%s', got: '%s'`, wrapped.StatusError, wrap.Status)
+	}
+
+	// body must still be readable
+	if again := client.ParseWrapped(resp); again == nil || again.Message != "msg" {
+		t.Fatal(`expected body to be restored`)
+	}
+}
+
+func TestParseWrapped_NoJSON(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	http.Error(rr, "plain", http.StatusInternalServerError)
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+
+	if wrap := client.ParseWrapped(resp); wrap != nil {
+		t.Fatalf(`expected nil, got: '%+v'`, wrap)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		header string
+		delay  time.Duration
+		ok     bool
+	}{
+		"missing":       {"", 0, false},
+		"seconds":       {"120", 2 * time.Minute, true},
+		"negative":      {"-1", 0, false},
+		"http date":     {now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		"http date old": {now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		"invalid":       {"soon", 0, false},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{Header: http.Header{}}
+			if tc.header != "" {
+				resp.Header.Set("Retry-After", tc.header)
+			}
+
+			delay, ok := client.RetryAfter(resp, now)
+			if ok != tc.ok || delay != tc.delay {
+				t.Fatalf(`expected '%s, %t', got: '%s, %t'`, tc.delay, tc.ok, delay, ok)
+			}
+		})
+	}
+}
+
+func TestParseWrapped_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	rr := httptest.NewRecorder()
+	rest.Ok(rr, req, strings.Repeat("a", int(client.MaxWrappedSize)))
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+
+	if wrap := client.ParseWrapped(resp); wrap != nil {
+		t.Fatal(`expected nil for a body exceeding MaxWrappedSize`)
+	}
+
+	// body must still be readable
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || int64(len(body)) <= client.MaxWrappedSize {
+		t.Fatalf(`expected complete body, got %d bytes`, len(body))
+	}
+}
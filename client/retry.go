@@ -0,0 +1,250 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+const (
+	// DefaultMaxRetries is used by NewRetryTransport.
+	DefaultMaxRetries = 3
+	// DefaultMinBackoff is used by NewRetryTransport.
+	DefaultMinBackoff = 100 * time.Millisecond
+	// DefaultMaxBackoff is used by NewRetryTransport.
+	DefaultMaxBackoff = 10 * time.Second
+	// DefaultJitter is used by NewRetryTransport.
+	DefaultJitter = 0.5
+)
+
+// Attempt describes the outcome of a single round trip.
+type Attempt struct {
+	// Request is the request which has been sent.
+	Request *http.Request
+	// Response is the received response, nil if Err is set.
+	Response *http.Response
+	// Wrapped is the decoded body of a 5XX Response, nil if the body is not a wrapped.Response.
+	Wrapped *wrapped.Response
+	// Err is the error returned by the underlying http.RoundTripper.
+	Err error
+	// Retries is the number of retries which have already been made.
+	Retries int
+}
+
+// RetryPolicy decides if an Attempt should be retried.
+type RetryPolicy func(a *Attempt) bool
+
+// DefaultRetryPolicy will retry an Attempt if
+//  - the decoded wrapped.Response has the status “fail” (HTTP status code 5XX),
+//  - or the method is idempotent and the request failed, hit a rate limit (429)
+//    or a gateway error (502, 503, 504).
+func DefaultRetryPolicy(a *Attempt) bool {
+	if a.Wrapped != nil && a.Wrapped.Status == wrapped.StatusFail {
+		return true
+	}
+
+	if !IsIdempotent(a.Request.Method) {
+		return false
+	}
+
+	if a.Err != nil {
+		return a.Request.Context().Err() == nil
+	}
+
+	switch a.Response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// IsIdempotent reports if method is idempotent as defined in RFC 7231 section 4.2.2.
+func IsIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// RetryTransport is a http.RoundTripper which will retry requests based on a RetryPolicy.
+//
+// The delay between two attempts grows exponentially from MinBackoff up to MaxBackoff.
+// If a 429 or 503 response contains a Retry-After header (see rest.TooManyRequests and
+// rest.ServiceUnavailable), the header value will be used instead. If it exceeds MaxBackoff,
+// no retry will be made and the response will be returned.
+//
+// The total time spent is limited by the deadline of the request context. A retry
+// will not be made if the delay would exceed the deadline, instead the last response
+// will be returned.
+type RetryTransport struct {
+	// Next will execute the requests. If nil, http.DefaultTransport will be used.
+	Next http.RoundTripper
+	// Policy decides if a request should be retried. If nil, DefaultRetryPolicy will be used.
+	Policy RetryPolicy
+	// MaxRetries is the maximum amount of retries, 0 disables retries.
+	MaxRetries int
+	// MinBackoff is the delay before the first retry.
+	MinBackoff time.Duration
+	// MaxBackoff caps the exponential backoff and the accepted Retry-After delay.
+	MaxBackoff time.Duration
+	// Jitter is the fraction (0 - 1) of the delay which will be randomized.
+	Jitter float64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewRetryTransport will create a RetryTransport with default values.
+func NewRetryTransport(next http.RoundTripper) *RetryTransport {
+	return &RetryTransport{
+		Next:       next,
+		MaxRetries: DefaultMaxRetries,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		Jitter:     DefaultJitter,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	getBody, err := bodyFunc(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := req.Context()
+	for retries := 0; ; retries++ {
+		attemptReq, err := cloneRequest(req, getBody)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.next().RoundTrip(attemptReq)
+		attempt := &Attempt{Request: attemptReq, Response: resp, Err: err, Retries: retries}
+		if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+			attempt.Wrapped = ParseWrapped(resp)
+		}
+
+		if retries >= t.MaxRetries || !t.policy()(attempt) {
+			return resp, err
+		}
+
+		delay := t.backoff(retries)
+		if after, ok := RetryAfter(resp, time.Now()); ok && isRetryAfterStatus(resp) {
+			if t.MaxBackoff > 0 && after > t.MaxBackoff {
+				return resp, err
+			}
+			delay = after
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+
+		if resp != nil {
+			drain(resp.Body)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (t *RetryTransport) next() http.RoundTripper {
+	if t.Next != nil {
+		return t.Next
+	}
+
+	return http.DefaultTransport
+}
+
+func (t *RetryTransport) policy() RetryPolicy {
+	if t.Policy != nil {
+		return t.Policy
+	}
+
+	return DefaultRetryPolicy
+}
+
+func (t *RetryTransport) backoff(retries int) time.Duration {
+	delay := float64(t.MinBackoff) * math.Pow(2, float64(retries))
+	if t.MaxBackoff > 0 && delay > float64(t.MaxBackoff) {
+		delay = float64(t.MaxBackoff)
+	}
+
+	if t.Jitter > 0 {
+		jitter := math.Min(t.Jitter, 1)
+		delay -= delay * jitter * t.random()
+	}
+
+	return time.Duration(delay)
+}
+
+func (t *RetryTransport) random() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.rand == nil {
+		t.rand = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec // jitter does not need crypto/rand
+	}
+
+	return t.rand.Float64()
+}
+
+func isRetryAfterStatus(resp *http.Response) bool {
+	return resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable)
+}
+
+// bodyFunc will return a function which returns a fresh copy of the body of req.
+//
+// If req has no GetBody, the body will be buffered, so it can be sent multiple times.
+func bodyFunc(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		return req.GetBody, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}, nil
+}
+
+func cloneRequest(req *http.Request, getBody func() (io.ReadCloser, error)) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if getBody == nil {
+		return clone, nil
+	}
+
+	body, err := getBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	clone.GetBody = getBody
+
+	return clone, nil
+}
@@ -0,0 +1,298 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/client"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+func newTransport() *client.RetryTransport {
+	return &client.RetryTransport{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+}
+
+func TestRetryTransport_RetriesFail(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			rest.InternalServerError(w, r, "unittest")
+			return
+		}
+		rest.Ok(w, r, "ok")
+	}))
+	defer srv.Close()
+
+	c := &http.Client{Transport: newTransport()}
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf(`expected 3 calls, got: '%d'`, n)
+	}
+}
+
+func TestRetryTransport_RetriesFailWithBody(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf(`expected body to be 'payload', got: '%s'`, body)
+		}
+		if atomic.AddInt32(&calls, 1) < 2 {
+			rest.InternalServerError(w, r, "unittest")
+			return
+		}
+		rest.Created(w, r, nil)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, ioutil.NopCloser(strings.NewReader("payload")))
+	resp, err := newTransport().RoundTrip(req)
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusCreated, resp.StatusCode)
+	}
+}
+
+func TestRetryTransport_NoRetryForNonIdempotentError(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		rest.TooManyRequests(w, r, "unittest")
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, nil)
+	resp, err := newTransport().RoundTrip(req)
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+	defer resp.Body.Close()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf(`expected 1 call, got: '%d'`, n)
+	}
+}
+
+func TestRetryTransport_MaxRetries(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		rest.TooManyRequests(w, r, "unittest")
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := newTransport().RoundTrip(req)
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Fatalf(`expected 4 calls, got: '%d'`, n)
+	}
+}
+
+func TestRetryTransport_RetryAfterExceedsDeadline(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "120")
+		rest.ServiceUnavailable(w, r, "unittest")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := newTransport().RoundTrip(req)
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf(`expected 1 call, got: '%d'`, n)
+	}
+}
+
+func TestRetryTransport_RetryAfterExceedsMaxBackoff(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "86400")
+		rest.TooManyRequests(w, r, "unittest")
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := newTransport().RoundTrip(req)
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf(`expected 1 call, got: '%d'`, n)
+	}
+}
+
+// endlessBody is a response body which never ends.
+type endlessBody struct {
+	closed int32
+}
+
+func (b *endlessBody) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+	return len(p), nil
+}
+
+func (b *endlessBody) Close() error {
+	atomic.StoreInt32(&b.closed, 1)
+	return nil
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+func TestRetryTransport_DrainEndlessBody(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	first := &endlessBody{}
+	tr := newTransport()
+	tr.Next = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: first, Request: r}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("ok")), Request: r}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, resp.StatusCode)
+	}
+	if atomic.LoadInt32(&first.closed) != 1 {
+		t.Fatal(`expected the drained body to be closed`)
+	}
+}
+
+func TestRetryTransport_CustomPolicy(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		rest.InternalServerError(w, r, "unittest")
+	}))
+	defer srv.Close()
+
+	transport := newTransport()
+	transport.Policy = func(a *client.Attempt) bool {
+		return false
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+	defer resp.Body.Close()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf(`expected 1 call, got: '%d'`, n)
+	}
+}
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method string
+		code   int
+		retry  bool
+	}{
+		"get fail":             {http.MethodGet, http.StatusInternalServerError, true},
+		"post fail":            {http.MethodPost, http.StatusInternalServerError, true},
+		"get too many":         {http.MethodGet, http.StatusTooManyRequests, true},
+		"post too many":        {http.MethodPost, http.StatusTooManyRequests, false},
+		"get bad request":      {http.MethodGet, http.StatusBadRequest, false},
+		"put ok":               {http.MethodPut, http.StatusOK, false},
+		"delete gateway error": {http.MethodDelete, http.StatusGatewayTimeout, true},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tc.method, "/unittest", nil)
+			rr := httptest.NewRecorder()
+			rest.Render(rr, req, &wrapped.Response{Code: tc.code})
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+
+			a := &client.Attempt{Request: req, Response: resp, Wrapped: client.ParseWrapped(resp)}
+			if retry := client.DefaultRetryPolicy(a); retry != tc.retry {
+				t.Fatalf(`expected retry to be '%t', got: '%t'`, tc.retry, retry)
+			}
+		})
+	}
+}
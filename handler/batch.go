@@ -0,0 +1,367 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+type ctxKey string
+
+const ctxKeyBatch = ctxKey("batch")
+
+// DefaultMaxBatchRequests is used by Batch if BatchConfig.MaxRequests is 0.
+const DefaultMaxBatchRequests = 20
+
+// batchStripHeaders are specific to the batch request and will not be inherited by sub-requests,
+// e.g. replaying the idempotency key would reject the second POST of a batch.
+var batchStripHeaders = []string{
+	"Accept",
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Type",
+	"Digest",
+	"Idempotency-Key",
+	"Signature",
+	"Signature-Input",
+	"X-Signature",
+	"X-Signature-Timestamp",
+}
+
+// BatchRequest is a sub-request of Batch.
+type BatchRequest struct {
+	// ID identifies the sub-request in DependsOn and its BatchResult, it is optional.
+	ID string `json:"id,omitempty"`
+	// Method is the HTTP method, it defaults to GET.
+	Method string `json:"method,omitempty"`
+	// Path is the absolute path including the query, e.g. "/api/users?limit=10".
+	Path string `json:"path"`
+	// Headers will be set on the sub-request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the JSON body of the sub-request.
+	Body json.RawMessage `json:"body,omitempty"`
+	// DependsOn contains the IDs of sub-requests which must succeed before this one starts.
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// BatchResult is the wrapped.Response of a BatchRequest.
+type BatchResult struct {
+	// ID is the ID of the BatchRequest.
+	ID string `json:"id,omitempty"`
+	wrapped.Response
+}
+
+// BatchConfig is the configuration of Batch.
+type BatchConfig struct {
+	// MaxRequests is the maximum amount of sub-requests in a batch. It defaults to
+	// DefaultMaxBatchRequests.
+	MaxRequests int
+	// Concurrency is the maximum amount of concurrently served sub-requests. If 0 or 1, the
+	// sub-requests will be served sequentially in order, dependencies first.
+	Concurrency int
+	// StripHeaders contains further headers of the batch request which will not be inherited by
+	// sub-requests, e.g. a custom middleware.IdempotencyConfig.Header.
+	StripHeaders []string
+}
+
+// Batch returns a handler which accepts a JSON array of BatchRequest and serves them
+// in-process with h. It responds with rest.Ok and a BatchResult for each BatchRequest in the
+// same order, each containing the code of the sub-request. A panicking sub-request results in
+// http.StatusInternalServerError.
+//
+// Sub-requests inherit the context and headers of the batch request, e.g. Authorization.
+// Headers specific to the batch request like Idempotency-Key, Content-Encoding and signatures
+// of middleware.VerifySignature are not inherited, see BatchConfig.StripHeaders.
+// A sub-request with DependsOn starts after its dependencies. If one of them did not succeed,
+// it will not be served and its result has http.StatusFailedDependency. Unknown or cyclic
+// dependencies, duplicate IDs and too many sub-requests are rejected with rest.BadRequest.
+//
+//  POST /api/batch
+//  [
+//      {"id": "user", "method": "POST", "path": "/api/users", "body": {"name": "alice"}},
+//      {"method": "GET", "path": "/api/users?limit=10", "dependsOn": ["user"]}
+//  ]
+//
+//  r.Post("/api/batch", handler.Batch(router, handler.BatchConfig{Concurrency: 4}))
+func Batch(h http.Handler, cfg BatchConfig) http.HandlerFunc {
+	maxRequests := cfg.MaxRequests
+	if maxRequests <= 0 {
+		maxRequests = DefaultMaxBatchRequests
+	}
+	strip := append(append([]string(nil), batchStripHeaders...), cfg.StripHeaders...)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if nested, _ := r.Context().Value(ctxKeyBatch).(bool); nested {
+			rest.BadRequest(w, r, "batch requests cannot be nested")
+			return
+		}
+
+		var requests []BatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			rest.BadRequest(w, r, "body must be a JSON array of batch requests")
+			return
+		}
+		if len(requests) > maxRequests {
+			rest.BadRequest(w, r, fmt.Sprintf("batch contains more than %d requests", maxRequests))
+			return
+		}
+
+		order, err := batchOrder(requests)
+		if err != nil {
+			rest.BadRequest(w, r, err.Error())
+			return
+		}
+
+		rest.Ok(w, r, serveBatch(r, h, requests, order, cfg.Concurrency, strip))
+	}
+}
+
+// batchOrder validates requests and returns their indexes in the order to serve them, each
+// after its dependencies. It reports duplicate IDs and unknown or cyclic dependencies.
+func batchOrder(requests []BatchRequest) ([]int, error) {
+	index := make(map[string]int, len(requests))
+	for i, req := range requests {
+		if req.ID == "" {
+			continue
+		}
+		if _, ok := index[req.ID]; ok {
+			return nil, fmt.Errorf("duplicate batch request id %q", req.ID)
+		}
+		index[req.ID] = i
+	}
+
+	for _, req := range requests {
+		for _, dep := range req.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("unknown batch request id %q in dependsOn", dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(requests))
+	order := make([]int, 0, len(requests))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("cyclic dependency of batch request id %q", requests[i].ID)
+		case visited:
+			return nil
+		}
+
+		state[i] = visiting
+		for _, dep := range requests[i].DependsOn {
+			if err := visit(index[dep]); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		order = append(order, i)
+
+		return nil
+	}
+
+	for i := range requests {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// batch serves the sub-requests of a batch request.
+type batch struct {
+	r        *http.Request
+	h        http.Handler
+	requests []BatchRequest
+	results  []BatchResult
+	index    map[string]int
+	strip    []string
+}
+
+func serveBatch(r *http.Request, h http.Handler, requests []BatchRequest, order []int, concurrency int, strip []string) []BatchResult {
+	b := &batch{
+		r:        r,
+		h:        h,
+		strip:    strip,
+		requests: requests,
+		results:  make([]BatchResult, len(requests)),
+		index:    make(map[string]int, len(requests)),
+	}
+	for i, req := range requests {
+		if req.ID != "" {
+			b.index[req.ID] = i
+		}
+	}
+
+	if concurrency <= 1 {
+		for _, i := range order {
+			b.serve(i)
+		}
+		return b.results
+	}
+
+	done := make([]chan struct{}, len(requests))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for _, i := range order {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, dep := range requests[i].DependsOn {
+				<-done[b.index[dep]]
+			}
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			b.serve(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return b.results
+}
+
+// serve serves the sub-request i, its dependencies must be served before. A panic of the
+// handler results in http.StatusInternalServerError, its value is only shown with ShowError.
+func (b *batch) serve(i int) {
+	req := b.requests[i]
+	b.results[i].ID = req.ID
+
+	defer func() {
+		if p := recover(); p != nil {
+			b.results[i].Response = wrapped.Response{
+				Code: http.StatusInternalServerError,
+				Err:  fmt.Errorf("batch request panicked: %v", p),
+			}
+			b.results[i].Parse(b.r.Context())
+		}
+	}()
+
+	for _, dep := range req.DependsOn {
+		if b.results[b.index[dep]].Code >= http.StatusBadRequest {
+			b.results[i].Response = wrapped.Response{
+				Code:    http.StatusFailedDependency,
+				Message: fmt.Sprintf("dependency %q failed", dep),
+			}
+			b.results[i].Parse(b.r.Context())
+			return
+		}
+	}
+
+	b.results[i].Response = serveBatchRequest(b.r, b.h, req, b.strip)
+}
+
+// serveBatchRequest serves req with h and returns its wrapped.Response. The headers strip of r
+// will not be inherited.
+func serveBatchRequest(r *http.Request, h http.Handler, req BatchRequest, strip []string) wrapped.Response {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	if !strings.HasPrefix(req.Path, "/") || strings.HasPrefix(req.Path, "//") {
+		res := wrapped.Response{Code: http.StatusBadRequest, Message: "path must be absolute, e.g. /api/users"}
+		res.Parse(r.Context())
+		return res
+	}
+
+	ctx := context.WithValue(r.Context(), ctxKeyBatch, true)
+	sub, err := http.NewRequestWithContext(ctx, method, req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		res := wrapped.Response{Code: http.StatusBadRequest, Err: err}
+		res.Parse(r.Context())
+		return res
+	}
+
+	sub.RequestURI = req.Path
+	sub.Host = r.Host
+	sub.RemoteAddr = r.RemoteAddr
+	sub.TLS = r.TLS
+	sub.Header = r.Header.Clone()
+	for _, key := range strip {
+		sub.Header.Del(key)
+	}
+	if len(req.Body) > 0 {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range req.Headers {
+		sub.Header.Set(key, value)
+	}
+
+	rec := &batchRecorder{header: make(http.Header)}
+	h.ServeHTTP(rec, sub)
+
+	return rec.response(r.Context())
+}
+
+// batchRecorder records the response of a sub-request.
+type batchRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (rec *batchRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *batchRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+}
+
+func (rec *batchRecorder) Write(p []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(p)
+}
+
+// response returns the recorded wrapped.Response. Bodies which are no wrapped.Response will
+// be set as Data.
+func (rec *batchRecorder) response(ctx context.Context) wrapped.Response {
+	code := rec.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	var res wrapped.Response
+	mediaType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+	isJSON := mediaType == rest.MediaTypeJSON || strings.HasSuffix(mediaType, "+json")
+
+	switch {
+	case rec.body.Len() == 0:
+	case isJSON && json.Unmarshal(rec.body.Bytes(), &res) == nil && res.Code != 0:
+	case isJSON && json.Valid(rec.body.Bytes()):
+		res.Data = json.RawMessage(rec.body.Bytes())
+	default:
+		res.Data = rec.body.String()
+	}
+
+	res.Code = code
+	res.Parse(ctx)
+
+	return res
+}
@@ -0,0 +1,312 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/handler"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/resttest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+type batchResult struct {
+	ID      string      `json:"id"`
+	Code    int         `json:"code"`
+	Status  string      `json:"status"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+func batchMux(cfg handler.BatchConfig) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rest.Ok(w, r, []string{"alice"})
+			return
+		}
+		var user map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			rest.BadRequest(w, r, "invalid user")
+			return
+		}
+		rest.Created(w, r, user)
+	})
+	mux.HandleFunc("/headers", func(w http.ResponseWriter, r *http.Request) {
+		rest.Ok(w, r, map[string]string{
+			"authorization":   r.Header.Get("Authorization"),
+			"x-test":          r.Header.Get("X-Test"),
+			"query":           r.URL.Query().Get("q"),
+			"idempotency-key": r.Header.Get("Idempotency-Key"),
+			"x-signature":     r.Header.Get("X-Signature"),
+			"x-custom":        r.Header.Get("X-Custom"),
+		})
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "hello")
+	})
+	mux.Handle("/batch", handler.Batch(mux, cfg))
+
+	return mux
+}
+
+func serveBatch(t *testing.T, h http.Handler, body string) (*http.Response, []batchResult) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+
+	var res struct {
+		Code    int           `json:"code"`
+		Message string        `json:"message"`
+		Data    []batchResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatalf(`did not expect error, got: '%s'`, err)
+	}
+	if res.Code != resp.StatusCode {
+		t.Fatalf(`expected Code to be '%d', got: '%d'`, resp.StatusCode, res.Code)
+	}
+
+	return resp, res.Data
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+
+	for _, concurrency := range []int{0, 4} {
+		concurrency := concurrency
+
+		t.Run("concurrency "+strconv.Itoa(concurrency), func(t *testing.T) {
+			t.Parallel()
+
+			body := `[
+				{"id": "list", "path": "/users"},
+				{"id": "create", "method": "post", "path": "/users", "body": {"name": "bob"}},
+				{"path": "/headers?q=1", "headers": {"X-Test": "yes"}, "dependsOn": ["create"]},
+				{"id": "missing", "path": "/missing"},
+				{"path": "/users", "dependsOn": ["list", "missing"]},
+				{"path": "/text"},
+				{"path": "https://example.com/users"},
+				{"path": "/batch", "method": "POST", "body": []}
+			]`
+
+			resp, results := serveBatch(t, batchMux(handler.BatchConfig{Concurrency: concurrency}), body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, resp.StatusCode)
+			}
+
+			codes := []int{200, 201, 200, 404, 424, 200, 400, 400}
+			if len(results) != len(codes) {
+				t.Fatalf(`expected %d results, got: '%v'`, len(codes), results)
+			}
+			for i, code := range codes {
+				if results[i].Code != code {
+					t.Fatalf(`expected result %d to have Code '%d', got: '%v'`, i, code, results[i])
+				}
+			}
+
+			if results[0].ID != "list" || results[0].Status != "success" {
+				t.Fatalf(`expected result of "list", got: '%v'`, results[0])
+			}
+			if data, _ := results[1].Data.(map[string]interface{}); data["name"] != "bob" {
+				t.Fatalf(`expected created user, got: '%v'`, results[1].Data)
+			}
+			headers, _ := results[2].Data.(map[string]interface{})
+			if headers["authorization"] != "Bearer token" || headers["x-test"] != "yes" || headers["query"] != "1" {
+				t.Fatalf(`expected inherited headers and query, got: '%v'`, results[2].Data)
+			}
+			if results[4].Message != `dependency "missing" failed` {
+				t.Fatalf(`expected failed dependency, got: '%v'`, results[4])
+			}
+			if results[5].Data != "hello" {
+				t.Fatalf(`expected text as Data, got: '%v'`, results[5].Data)
+			}
+			if results[7].Message != "batch requests cannot be nested" {
+				t.Fatalf(`expected nested batch to be rejected, got: '%v'`, results[7])
+			}
+		})
+	}
+}
+
+func TestBatch_StripHeaders(t *testing.T) {
+	t.Parallel()
+
+	h := batchMux(handler.BatchConfig{StripHeaders: []string{"X-Custom"}})
+	body := `[{"path": "/headers"}, {"path": "/headers", "headers": {"Idempotency-Key": "sub"}}]`
+
+	req := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Idempotency-Key", "batch")
+	req.Header.Set("X-Signature", "sha256=abc")
+	req.Header.Set("X-Custom", "custom")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	var res struct {
+		Data []batchResult `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatalf(`did not expect error, got: '%s'`, err)
+	}
+	if len(res.Data) != 2 {
+		t.Fatalf(`expected 2 results, got: '%v'`, res.Data)
+	}
+
+	want := []map[string]interface{}{
+		{"authorization": "Bearer token", "x-test": "", "query": "", "idempotency-key": "", "x-signature": "", "x-custom": ""},
+		{"authorization": "Bearer token", "x-test": "", "query": "", "idempotency-key": "sub", "x-signature": "", "x-custom": ""},
+	}
+	for i, result := range res.Data {
+		if !reflect.DeepEqual(result.Data, want[i]) {
+			t.Fatalf(`expected headers '%v', got: '%v'`, want[i], result.Data)
+		}
+	}
+}
+
+func TestBatch_Order(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var order []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		order = append(order, r.URL.Path)
+		mu.Unlock()
+		rest.NoContent(w, r)
+	})
+
+	body := `[{"path": "/a", "dependsOn": ["c"]}, {"path": "/b"}, {"id": "c", "path": "/c"}]`
+	_, results := serveBatch(t, handler.Batch(h, handler.BatchConfig{}), body)
+
+	if strings.Join(order, ",") != "/c,/a,/b" {
+		t.Fatalf(`expected order '/c,/a,/b', got: '%v'`, order)
+	}
+	if len(results) != 3 || results[2].ID != "c" || results[0].Code != http.StatusNoContent {
+		t.Fatalf(`expected results in request order, got: '%v'`, results)
+	}
+}
+
+func TestBatch_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	var inFlight, peak int32
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		rest.NoContent(w, r)
+	})
+
+	body := `[{"path": "/1"}, {"path": "/2"}, {"path": "/3"}, {"path": "/4"}, {"path": "/5"}, {"path": "/6"}]`
+	_, results := serveBatch(t, handler.Batch(h, handler.BatchConfig{Concurrency: 2}), body)
+
+	if len(results) != 6 {
+		t.Fatalf(`expected 6 results, got: '%v'`, results)
+	}
+	if p := atomic.LoadInt32(&peak); p != 2 {
+		t.Fatalf(`expected 2 concurrent sub-requests, got: '%d'`, p)
+	}
+}
+
+func TestBatch_Panic(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		concurrency int
+		showError   bool
+		msg         string
+	}{
+		"sequential":       {0, false, http.StatusText(http.StatusInternalServerError)},
+		"concurrent":       {4, false, http.StatusText(http.StatusInternalServerError)},
+		"sequential shown": {0, true, "batch request panicked: boom"},
+		"concurrent shown": {4, true, "batch request panicked: boom"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			body := `[{"path": "/panic"}, {"path": "/users"}]`
+			req := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+			req = req.WithContext(wrapped.CtxSetShowError(req.Context(), tc.showError))
+			rr := httptest.NewRecorder()
+			batchMux(handler.BatchConfig{Concurrency: tc.concurrency}).ServeHTTP(rr, req)
+
+			var res struct {
+				Data []batchResult `json:"data"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			if rr.Code != http.StatusOK || len(res.Data) != 2 {
+				t.Fatalf(`expected 2 results, got: '%d' '%v'`, rr.Code, res.Data)
+			}
+			if res.Data[0].Code != http.StatusInternalServerError || res.Data[0].Message != tc.msg {
+				t.Fatalf(`expected '%d' with message '%s', got: '%v'`, http.StatusInternalServerError, tc.msg, res.Data[0])
+			}
+			if res.Data[1].Code != http.StatusOK {
+				t.Fatalf(`expected other requests to be served, got: '%v'`, res.Data[1])
+			}
+		})
+	}
+}
+
+func TestBatch_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		body string
+		msg  string
+	}{
+		"no array":      {`{"path": "/users"}`, "body must be a JSON array of batch requests"},
+		"too many":      {`[{"path": "/1"}, {"path": "/2"}, {"path": "/3"}]`, "batch contains more than 2 requests"},
+		"duplicate id":  {`[{"id": "a", "path": "/1"}, {"id": "a", "path": "/2"}]`, `duplicate batch request id "a"`},
+		"unknown id":    {`[{"path": "/1", "dependsOn": ["a"]}]`, `unknown batch request id "a" in dependsOn`},
+		"cyclic":        {`[{"id": "a", "path": "/1", "dependsOn": ["b"]}, {"id": "b", "path": "/2", "dependsOn": ["a"]}]`, `cyclic dependency of batch request id "a"`},
+		"self reliance": {`[{"id": "a", "path": "/1", "dependsOn": ["a"]}]`, `cyclic dependency of batch request id "a"`},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("POST", "/batch", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			handler.Batch(batchMux(handler.BatchConfig{}), handler.BatchConfig{MaxRequests: 2})(rr, req)
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusAndMessage(t, resp, wrap, http.StatusBadRequest, tc.msg)
+		})
+	}
+}
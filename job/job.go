@@ -0,0 +1,139 @@
+// Package job provides asynchronous jobs on top of rest.Accepted.
+//
+// A submitted job responds with 202 Accepted and a Location header pointing to its status
+// resource. The status resource renders the Job as wrapped.Response and redirects with
+// 303 See Other to the result once the job succeeded.
+//
+//	// Example with chi
+//
+//	jobs := job.NewManager("/api/jobs", nil)
+//
+//	c := chi.NewMux()
+//	c.Mount("/api/jobs", jobs)
+//	c.Post("/api/reports", func(w http.ResponseWriter, r *http.Request) {
+//	    jobs.Submit(w, r, func(ctx context.Context) (interface{}, error) {
+//	        return buildReport(ctx)
+//	    })
+//	})
+package job
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// State is the state of a Job.
+type State string
+
+const (
+	// StatePending is the state of a Job which has not been started yet.
+	StatePending State = "pending"
+	// StateRunning is the state of a Job which is being processed.
+	StateRunning State = "running"
+	// StateSucceeded is the state of a Job which finished without error.
+	StateSucceeded State = "succeeded"
+	// StateFailed is the state of a Job which finished with an error.
+	StateFailed State = "failed"
+	// StateCanceled is the state of a Job which has been canceled.
+	StateCanceled State = "canceled"
+)
+
+// Done reports if the state is final.
+func (s State) Done() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateCanceled
+}
+
+// Job is the status resource of an asynchronous job.
+type Job struct {
+	ID    string `json:"id"`
+	State State  `json:"state"`
+	// Error describes why a Job failed. Like wrapped.Response.Message it only contains the
+	// error of fn for wrapped.MsgResponder, 4XX errors or if ShowError was set on Submit.
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Result contains the result of a succeeded job. It will be rendered by the result resource.
+	Result interface{} `json:"-"`
+}
+
+// Func is the work of a Job. The context will be canceled if the Job gets canceled.
+type Func func(ctx context.Context) (interface{}, error)
+
+// NotFoundError will be returned by Store if a Job does not exist.
+//
+// It implements wrapped.StatusCodeResponder, so rest.Error will respond with http.StatusNotFound.
+type NotFoundError struct {
+	ID string
+}
+
+func (e *NotFoundError) Error() string {
+	return "job " + e.ID + " not found"
+}
+
+// StatusCode implements wrapped.StatusCodeResponder.
+func (e *NotFoundError) StatusCode() int {
+	return http.StatusNotFound
+}
+
+// Store persists jobs.
+type Store interface {
+	// Save will create or replace job.
+	Save(ctx context.Context, job *Job) error
+	// Get returns the Job with id or a *NotFoundError.
+	Get(ctx context.Context, id string) (*Job, error)
+}
+
+// MemoryStore is a Store which keeps the jobs in memory.
+//
+// Finished jobs will be evicted after TTL, a TTL of 0 keeps them forever.
+type MemoryStore struct {
+	TTL time.Duration
+
+	mu        sync.Mutex
+	jobs      map[string]Job
+	lastSweep time.Time
+}
+
+// NewMemoryStore will create a MemoryStore.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{TTL: ttl, jobs: make(map[string]Job), lastSweep: time.Now()}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evict(time.Now())
+	s.jobs[job.ID] = *job
+
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, &NotFoundError{ID: id}
+	}
+
+	return &job, nil
+}
+
+func (s *MemoryStore) evict(now time.Time) {
+	if s.TTL <= 0 || now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+
+	s.lastSweep = now
+	for id, job := range s.jobs {
+		if job.State.Done() && now.Sub(job.UpdatedAt) > s.TTL {
+			delete(s.jobs, id)
+		}
+	}
+}
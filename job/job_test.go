@@ -0,0 +1,60 @@
+package job_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/job"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	store := job.NewMemoryStore(time.Hour)
+	ctx := context.Background()
+
+	if err := store.Save(ctx, &job.Job{ID: "1", State: job.StatePending}); err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+
+	j, err := store.Get(ctx, "1")
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+	if j.State != job.StatePending {
+		t.Fatalf(`expected State to be '%s', got: '%s'`, job.StatePending, j.State)
+	}
+
+	// modifying the returned job must not modify the store
+	j.State = job.StateRunning
+	if j, _ := store.Get(ctx, "1"); j.State != job.StatePending {
+		t.Fatal(`expected store to return a copy`)
+	}
+
+	_, err = store.Get(ctx, "2")
+	var notFound *job.NotFoundError
+	if !errors.As(err, &notFound) || notFound.StatusCode() != http.StatusNotFound {
+		t.Fatalf(`expected NotFoundError, got: '%v'`, err)
+	}
+}
+
+func TestState_Done(t *testing.T) {
+	t.Parallel()
+
+	tests := map[job.State]bool{
+		job.StatePending:   false,
+		job.StateRunning:   false,
+		job.StateSucceeded: true,
+		job.StateFailed:    true,
+		job.StateCanceled:  true,
+	}
+
+	for state, done := range tests {
+		if state.Done() != done {
+			t.Fatalf(`expected '%s'.Done() to be '%t'`, state, done)
+		}
+	}
+}
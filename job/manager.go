@@ -0,0 +1,237 @@
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// Manager runs jobs and serves their status resources below Prefix:
+//
+//  GET    {Prefix}/{id}        renders the Job, redirects with 303 See Other to the result once succeeded
+//  GET    {Prefix}/{id}/result renders the result of a succeeded Job
+//  DELETE {Prefix}/{id}        cancels the Job
+type Manager struct {
+	// Prefix is the path of the status resources, e.g. "/api/jobs".
+	Prefix string
+	// Store persists the jobs.
+	Store Store
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// NewManager will create a Manager. If store is nil, a MemoryStore which keeps finished
+// jobs for an hour will be used.
+func NewManager(prefix string, store Store) *Manager {
+	if store == nil {
+		store = NewMemoryStore(time.Hour)
+	}
+
+	return &Manager{
+		Prefix:  strings.TrimSuffix(prefix, "/"),
+		Store:   store,
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// Location returns the path of the status resource of the Job with id.
+func (m *Manager) Location(id string) string {
+	return m.Prefix + "/" + id
+}
+
+// Submit will start fn in the background and responds with rest.Accepted. The absolute Location
+// header and the "self" link point to the status resource and Data contains the Job.
+// If fn panics, the Job fails.
+func (m *Manager) Submit(w http.ResponseWriter, r *http.Request, fn Func) {
+	now := time.Now()
+	job := &Job{ID: newID(), State: StatePending, CreatedAt: now, UpdatedAt: now}
+	if err := m.Store.Save(r.Context(), job); err != nil {
+		rest.Error(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	m.cancels[job.ID] = cancel
+	m.mu.Unlock()
+
+	go m.run(ctx, *job, fn, wrapped.ShowErrorFromCtx(r.Context()))
+
+	w.Header().Set("Location", m.Location(job.ID))
+	rest.Accepted(w, r, job)
+}
+
+// Cancel will cancel the Job with id. It returns the canceled Job.
+func (m *Manager) Cancel(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, err := m.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.State.Done() {
+		return job, &FinishedError{ID: id, State: job.State}
+	}
+
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+		delete(m.cancels, id)
+	}
+
+	job.State = StateCanceled
+	job.UpdatedAt = time.Now()
+
+	return job, m.Store.Save(ctx, job)
+}
+
+// FinishedError will be returned by Manager.Cancel if the Job has already finished.
+//
+// It implements wrapped.StatusCodeResponder, so rest.Error will respond with http.StatusConflict.
+type FinishedError struct {
+	ID    string
+	State State
+}
+
+func (e *FinishedError) Error() string {
+	return "job " + e.ID + " has already " + string(e.State)
+}
+
+// StatusCode implements wrapped.StatusCodeResponder.
+func (e *FinishedError) StatusCode() int {
+	return http.StatusConflict
+}
+
+func (m *Manager) run(ctx context.Context, job Job, fn Func, showError bool) {
+	if !m.transition(&job, StateRunning, nil, "") {
+		return
+	}
+
+	result, err := call(ctx, fn)
+	if err != nil {
+		// the Job is public, so its error follows the rules of wrapped.Response.Message
+		res := &wrapped.Response{Err: err}
+		res.Parse(wrapped.CtxSetShowError(context.Background(), showError))
+		m.transition(&job, StateFailed, nil, res.Message)
+		return
+	}
+
+	m.transition(&job, StateSucceeded, result, "")
+}
+
+// call runs fn and returns a panic as error.
+func call(ctx context.Context, fn Func) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// transition will update the state of job unless it has been canceled.
+func (m *Manager) transition(job *Job, state State, result interface{}, msg string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.cancels[job.ID]; !ok {
+		return false
+	}
+
+	job.State = state
+	job.Result = result
+	job.Error = msg
+	job.UpdatedAt = time.Now()
+	if state.Done() {
+		delete(m.cancels, job.ID)
+	}
+
+	_ = m.Store.Save(context.Background(), job)
+
+	return true
+}
+
+// ServeHTTP implements http.Handler to serve the status resources.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rel := strings.TrimPrefix(path.Clean(r.URL.Path), m.Prefix)
+	parts := strings.Split(strings.Trim(rel, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] != "" && r.Method == http.MethodGet:
+		m.serveStatus(w, r, parts[0])
+	case len(parts) == 1 && parts[0] != "" && r.Method == http.MethodDelete:
+		m.serveCancel(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "result" && r.Method == http.MethodGet:
+		m.serveResult(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "result":
+		w.Header().Set("Allow", http.MethodGet)
+		rest.Render(w, r, &wrapped.Response{Code: http.StatusMethodNotAllowed})
+	case len(parts) == 1 && parts[0] != "":
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodDelete)
+		rest.Render(w, r, &wrapped.Response{Code: http.StatusMethodNotAllowed})
+	default:
+		rest.NotFound(w, r, "")
+	}
+}
+
+func (m *Manager) serveStatus(w http.ResponseWriter, r *http.Request, id string) {
+	job, err := m.Store.Get(r.Context(), id)
+	if err != nil {
+		rest.Error(w, r, err)
+		return
+	}
+
+	if job.State == StateSucceeded {
+		w.Header().Set("Location", rest.ResolveURL(r, m.Location(id)+"/result"))
+		rest.Render(w, r, &wrapped.Response{Code: http.StatusSeeOther, Data: job})
+		return
+	}
+
+	rest.Ok(w, r, job)
+}
+
+func (m *Manager) serveResult(w http.ResponseWriter, r *http.Request, id string) {
+	job, err := m.Store.Get(r.Context(), id)
+	if err != nil {
+		rest.Error(w, r, err)
+		return
+	}
+
+	if job.State != StateSucceeded {
+		rest.NotFound(w, r, "job "+id+" has no result")
+		return
+	}
+
+	rest.Ok(w, r, job.Result)
+}
+
+func (m *Manager) serveCancel(w http.ResponseWriter, r *http.Request, id string) {
+	job, err := m.Cancel(r.Context(), id)
+	if err != nil {
+		rest.Error(w, r, err)
+		return
+	}
+
+	rest.Ok(w, r, job)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,224 @@
+package job_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/job"
+	"github.com/lanz-dev/go-rest/resttest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+func submit(t *testing.T, m *job.Manager, fn job.Func) string {
+	req := httptest.NewRequest("POST", "/api/reports", nil)
+	rr := httptest.NewRecorder()
+	m.Submit(rr, req, fn)
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusCode(t, resp, wrap, http.StatusAccepted)
+
+	data, ok := wrap.Data.(map[string]interface{})
+	if !ok || data["state"] != string(job.StatePending) {
+		t.Fatalf(`expected pending job in Data, got: '%v'`, wrap.Data)
+	}
+
+	location := resp.Header.Get("Location")
+	if location != "http://example.com"+m.Location(data["id"].(string)) {
+		t.Fatalf(`expected Location to be the status resource, got: '%s'`, location)
+	}
+	if len(wrap.Links) != 1 || wrap.Links[0].Href != location {
+		t.Fatalf(`expected self link to the status resource, got: '%v'`, wrap.Links)
+	}
+
+	return location
+}
+
+func waitForState(t *testing.T, m *job.Manager, location string, state job.State) *http.Response {
+	deadline := time.Now().Add(time.Second)
+	for {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", location, nil))
+
+		resp := rr.Result()
+		wrap := resttest.ParseToWrapped(t, resp.Body)
+		resp.Body.Close()
+		if data, ok := wrap.Data.(map[string]interface{}); ok && data["state"] == string(state) {
+			return resp
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf(`expected job to reach state '%s', got: '%v'`, state, wrap.Data)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestManager_Succeeded(t *testing.T) {
+	t.Parallel()
+
+	m := job.NewManager("/api/jobs", nil)
+	location := submit(t, m, func(ctx context.Context) (interface{}, error) {
+		return "report", nil
+	})
+
+	resp := waitForState(t, m, location, job.StateSucceeded)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusSeeOther, resp.StatusCode)
+	}
+
+	result := resp.Header.Get("Location")
+	if result != location+"/result" {
+		t.Fatalf(`expected Location to be the result, got: '%s'`, result)
+	}
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", result, nil))
+	resp = rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusCode(t, resp, wrap, http.StatusOK)
+
+	if wrap.Data != "report" {
+		t.Fatalf(`expected Data to be 'report', got: '%v'`, wrap.Data)
+	}
+}
+
+func TestManager_Failed(t *testing.T) {
+	t.Parallel()
+
+	m := job.NewManager("/api/jobs", nil)
+	location := submit(t, m, func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("unittest")
+	})
+
+	resp := waitForState(t, m, location, job.StateFailed)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, resp.StatusCode)
+	}
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", location+"/result", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestManager_FailedError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fn        job.Func
+		showError bool
+		msg       string
+	}{
+		"hidden": {func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("db password wrong")
+		}, false, http.StatusText(http.StatusInternalServerError)},
+		"shown": {func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("db password wrong")
+		}, true, "db password wrong"},
+		"panic": {func(ctx context.Context) (interface{}, error) {
+			panic("unittest")
+		}, false, http.StatusText(http.StatusInternalServerError)},
+		"panic shown": {func(ctx context.Context) (interface{}, error) {
+			panic("unittest")
+		}, true, "job panicked: unittest"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := job.NewManager("/api/jobs", nil)
+			req := httptest.NewRequest("POST", "/api/reports", nil)
+			req = req.WithContext(wrapped.CtxSetShowError(req.Context(), tc.showError))
+			rr := httptest.NewRecorder()
+			m.Submit(rr, req, tc.fn)
+
+			location := rr.Header().Get("Location")
+			waitForState(t, m, location, job.StateFailed)
+
+			rr = httptest.NewRecorder()
+			m.ServeHTTP(rr, httptest.NewRequest("GET", location, nil))
+			wrap := resttest.ParseToWrapped(t, rr.Body)
+			if data, _ := wrap.Data.(map[string]interface{}); data["error"] != tc.msg {
+				t.Fatalf(`expected error to be '%s', got: '%v'`, tc.msg, wrap.Data)
+			}
+		})
+	}
+}
+
+func TestManager_Cancel(t *testing.T) {
+	t.Parallel()
+
+	canceled := make(chan struct{})
+	m := job.NewManager("/api/jobs", nil)
+	location := submit(t, m, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	waitForState(t, m, location, job.StateRunning)
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("DELETE", location, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, rr.Code)
+	}
+	<-canceled
+
+	waitForState(t, m, location, job.StateCanceled)
+
+	rr = httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("DELETE", location, nil))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusConflict, rr.Code)
+	}
+}
+
+func TestManager_ServeHTTP_NotFound(t *testing.T) {
+	t.Parallel()
+
+	m := job.NewManager("/api/jobs/", nil)
+
+	tests := map[string]struct {
+		method string
+		path   string
+		code   int
+		allow  string
+	}{
+		"unknown job":         {"GET", "/api/jobs/unknown", http.StatusNotFound, ""},
+		"unknown result":      {"GET", "/api/jobs/unknown/result", http.StatusNotFound, ""},
+		"no id":               {"GET", "/api/jobs", http.StatusNotFound, ""},
+		"wrong method":        {"POST", "/api/jobs/unknown", http.StatusMethodNotAllowed, "GET, DELETE"},
+		"wrong result method": {"DELETE", "/api/jobs/unknown/result", http.StatusMethodNotAllowed, "GET"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, tc.code)
+
+			if allow := resp.Header.Get("Allow"); allow != tc.allow {
+				t.Fatalf(`expected Allow to be '%s', got: '%s'`, tc.allow, allow)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+const ctxKeyIdentity = ctxKey("identity")
+
+// Identity is an authenticated client.
+type Identity struct {
+	// ID identifies the client, e.g. the user name or the name of an api key.
+	ID string
+	// Scopes contains the granted scopes.
+	Scopes []string
+	// Roles contains the granted roles, see RoleHierarchy.
+	Roles []string
+}
+
+// HasScope reports if scope is granted to the Identity.
+func (i Identity) HasScope(scope string) bool {
+	return containsString(i.Scopes, scope)
+}
+
+// CtxSetIdentity will set identity on ctx.
+func CtxSetIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, ctxKeyIdentity, identity)
+}
+
+// IdentityFromCtx will get the Identity set by APIKey, BasicAuth or JWT on ctx.
+func IdentityFromCtx(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(ctxKeyIdentity).(Identity)
+	return identity, ok
+}
+
+// APIKeyStore looks up api keys.
+type APIKeyStore interface {
+	// LookupAPIKey returns the Identity of key. The second return value is false if the key is unknown.
+	LookupAPIKey(ctx context.Context, key string) (Identity, bool, error)
+}
+
+// StaticAPIKeys is an APIKeyStore which maps keys to identities.
+type StaticAPIKeys map[string]Identity
+
+// LookupAPIKey implements APIKeyStore. The keys will be compared in constant time.
+func (s StaticAPIKeys) LookupAPIKey(_ context.Context, key string) (Identity, bool, error) {
+	keyHash := sha256.Sum256([]byte(key))
+
+	var (
+		found Identity
+		ok    bool
+	)
+	for k, identity := range s {
+		h := sha256.Sum256([]byte(k))
+		if subtle.ConstantTimeCompare(keyHash[:], h[:]) == 1 {
+			found, ok = identity, true
+		}
+	}
+
+	return found, ok, nil
+}
+
+// DefaultAPIKeyHeader is used by APIKey if APIKeyConfig.Header and APIKeyConfig.Query are empty.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// APIKeyConfig is the configuration of APIKey.
+type APIKeyConfig struct {
+	// Store looks up the api keys.
+	Store APIKeyStore
+	// Header contains the api key.
+	Header string
+	// Query is the name of the query parameter which contains the api key. It will be used
+	// if the header is missing.
+	Query string
+	// Realm will be sent in the WWW-Authenticate header.
+	Realm string
+	// Scopes must all be granted to the Identity, otherwise the request will be rejected with rest.Forbidden.
+	Scopes []string
+}
+
+// APIKey will authenticate requests with an api key and sets the Identity on the
+// context, see IdentityFromCtx.
+//
+//  r.Use(middleware.APIKey(middleware.APIKeyConfig{
+//      Store: middleware.StaticAPIKeys{"secret": {ID: "billing", Scopes: []string{"invoices:read"}}},
+//  }))
+//
+// Requests with a missing or unknown key will be rejected with rest.Unauthorized.
+func APIKey(cfg APIKeyConfig) func(http.Handler) http.Handler {
+	if cfg.Header == "" && cfg.Query == "" {
+		cfg.Header = DefaultAPIKeyHeader
+	}
+
+	param := cfg.Header
+	if param == "" {
+		param = cfg.Query
+	}
+	challenge := fmt.Sprintf("APIKey realm=%q, in=%q", cfg.Realm, param)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var key string
+			if cfg.Header != "" {
+				key = r.Header.Get(cfg.Header)
+			}
+			if key == "" && cfg.Query != "" {
+				key = r.URL.Query().Get(cfg.Query)
+			}
+
+			if key == "" {
+				w.Header().Set("WWW-Authenticate", challenge)
+				rest.Unauthorized(w, r, "missing api key")
+				return
+			}
+
+			identity, ok, err := cfg.Store.LookupAPIKey(r.Context(), key)
+			if err != nil {
+				rest.Error(w, r, err)
+				return
+			}
+			if !ok {
+				w.Header().Set("WWW-Authenticate", challenge)
+				rest.Unauthorized(w, r, "invalid api key")
+				return
+			}
+
+			authenticated(w, r, next, identity, cfg.Scopes)
+		})
+	}
+}
+
+// BasicAuthStore looks up the credentials of HTTP Basic authentication.
+type BasicAuthStore interface {
+	// LookupBasicAuth returns the Identity of user. The second return value is false if
+	// the user is unknown or the password is wrong.
+	LookupBasicAuth(ctx context.Context, user, password string) (Identity, bool, error)
+}
+
+// BasicCredential is the password, the scopes and the roles of a user.
+type BasicCredential struct {
+	Password string
+	Scopes   []string
+	Roles    []string
+}
+
+// StaticBasicAuth is a BasicAuthStore which maps users to credentials.
+type StaticBasicAuth map[string]BasicCredential
+
+// LookupBasicAuth implements BasicAuthStore. The password will be compared in constant time.
+func (s StaticBasicAuth) LookupBasicAuth(_ context.Context, user, password string) (Identity, bool, error) {
+	credential, ok := s[user]
+
+	given := sha256.Sum256([]byte(password))
+	expected := sha256.Sum256([]byte(credential.Password))
+	if subtle.ConstantTimeCompare(given[:], expected[:]) != 1 || !ok {
+		return Identity{}, false, nil
+	}
+
+	return Identity{ID: user, Scopes: credential.Scopes, Roles: credential.Roles}, true, nil
+}
+
+// BasicAuthConfig is the configuration of BasicAuth.
+type BasicAuthConfig struct {
+	// Store looks up the credentials.
+	Store BasicAuthStore
+	// Realm will be sent in the WWW-Authenticate header.
+	Realm string
+	// Scopes must all be granted to the Identity, otherwise the request will be rejected with rest.Forbidden.
+	Scopes []string
+}
+
+// BasicAuth will authenticate requests with HTTP Basic authentication (RFC 7617) and sets
+// the Identity on the context, see IdentityFromCtx.
+//
+// Requests with missing or invalid credentials will be rejected with rest.Unauthorized.
+func BasicAuth(cfg BasicAuthConfig) func(http.Handler) http.Handler {
+	challenge := fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, cfg.Realm)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			if !ok {
+				w.Header().Set("WWW-Authenticate", challenge)
+				rest.Unauthorized(w, r, "missing credentials")
+				return
+			}
+
+			identity, ok, err := cfg.Store.LookupBasicAuth(r.Context(), user, password)
+			if err != nil {
+				rest.Error(w, r, err)
+				return
+			}
+			if !ok {
+				w.Header().Set("WWW-Authenticate", challenge)
+				rest.Unauthorized(w, r, "invalid credentials")
+				return
+			}
+
+			authenticated(w, r, next, identity, cfg.Scopes)
+		})
+	}
+}
+
+// authenticated will check the scopes of identity and passes it to next.
+func authenticated(w http.ResponseWriter, r *http.Request, next http.Handler, identity Identity, scopes []string) {
+	if missing := Scopes(scopes...).Missing(r, identity); len(missing) > 0 {
+		rest.Forbidden(w, r, "missing scope: "+strings.Join(missing, ", "))
+		return
+	}
+
+	next.ServeHTTP(w, r.WithContext(CtxSetIdentity(r.Context(), identity)))
+}
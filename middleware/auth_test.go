@@ -0,0 +1,185 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+func identityHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := middleware.IdentityFromCtx(r.Context())
+		if !ok {
+			t.Error(`expected identity in context`)
+		}
+		rest.Ok(w, r, identity.ID)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	t.Parallel()
+
+	store := middleware.StaticAPIKeys{
+		"secret":   {ID: "billing", Scopes: []string{"invoices:read"}},
+		"readonly": {ID: "readonly"},
+	}
+
+	tests := map[string]struct {
+		header string
+		query  string
+		code   int
+		msg    string
+	}{
+		"header":        {"secret", "", http.StatusOK, ""},
+		"query":         {"", "secret", http.StatusOK, ""},
+		"missing":       {"", "", http.StatusUnauthorized, "missing api key"},
+		"invalid":       {"wrong", "", http.StatusUnauthorized, "invalid api key"},
+		"missing scope": {"readonly", "", http.StatusForbidden, "missing scope: invoices:read"},
+	}
+
+	h := middleware.APIKey(middleware.APIKeyConfig{
+		Store:  store,
+		Header: "X-API-Key",
+		Query:  "api_key",
+		Realm:  "api",
+		Scopes: []string{"invoices:read"},
+	})(identityHandler(t))
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/unittest?api_key="+tc.query, nil)
+			if tc.header != "" {
+				req.Header.Set("X-API-Key", tc.header)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, tc.code)
+
+			switch tc.code {
+			case http.StatusOK:
+				if wrap.Data != "billing" {
+					t.Fatalf(`expected identity 'billing', got: '%v'`, wrap.Data)
+				}
+			case http.StatusUnauthorized:
+				resttest.ExpectMessage(t, wrap, tc.msg)
+				if challenge := resp.Header.Get("WWW-Authenticate"); challenge != `APIKey realm="api", in="X-API-Key"` {
+					t.Fatalf(`expected WWW-Authenticate challenge, got: '%s'`, challenge)
+				}
+			default:
+				resttest.ExpectMessage(t, wrap, tc.msg)
+			}
+		})
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) LookupAPIKey(context.Context, string) (middleware.Identity, bool, error) {
+	return middleware.Identity{}, false, errors.New("unittest")
+}
+
+func TestAPIKey_StoreError(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.APIKey(middleware.APIKeyConfig{Store: failingStore{}})(okHandler())
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	req.Header.Set(middleware.DefaultAPIKeyHeader, "key")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusInternalServerError, rr.Code)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	t.Parallel()
+
+	store := middleware.StaticBasicAuth{
+		"alice": {Password: "wonderland", Scopes: []string{"admin"}},
+		"bob":   {Password: "builder"},
+	}
+
+	tests := map[string]struct {
+		user     string
+		password string
+		code     int
+	}{
+		"valid":          {"alice", "wonderland", http.StatusOK},
+		"missing":        {"", "", http.StatusUnauthorized},
+		"wrong password": {"alice", "wrong", http.StatusUnauthorized},
+		"unknown user":   {"eve", "", http.StatusUnauthorized},
+		"missing scope":  {"bob", "builder", http.StatusForbidden},
+	}
+
+	h := middleware.BasicAuth(middleware.BasicAuthConfig{Store: store, Realm: "admin", Scopes: []string{"admin"}})(identityHandler(t))
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/unittest", nil)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, tc.code)
+
+			if tc.code == http.StatusUnauthorized {
+				if challenge := resp.Header.Get("WWW-Authenticate"); challenge != `Basic realm="admin", charset="UTF-8"` {
+					t.Fatalf(`expected WWW-Authenticate challenge, got: '%s'`, challenge)
+				}
+			}
+		})
+	}
+}
+
+func TestJWT_Identity(t *testing.T) {
+	t.Parallel()
+
+	keys := newTestKeys(t)
+	h := middleware.JWT(middleware.JWTConfig{Keys: keys.keySet()})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := middleware.IdentityFromCtx(r.Context())
+		if identity.ID != "user" || !identity.HasScope("read") || !identity.HasScope("write") {
+			t.Errorf(`expected identity with scopes, got: '%+v'`, identity)
+		}
+		rest.Ok(w, r, nil)
+	}))
+
+	for _, claims := range []map[string]interface{}{
+		{"sub": "user", "scope": "read write", "exp": time.Now().Unix() + 60},
+		{"sub": "user", "scp": []string{"read", "write"}},
+	} {
+		req := httptest.NewRequest("GET", "/unittest", nil)
+		req.Header.Set("Authorization", "Bearer "+signJWT(t, keys, middleware.AlgHS256, "", claims))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+// BodyTooLargeError will be returned when reading a body which exceeds the limit of MaxBodySize.
+//
+// It implements wrapped.StatusCodeResponder, so rest.Error will respond with http.StatusRequestEntityTooLarge.
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body too large, limit is %d bytes", e.Limit)
+}
+
+// StatusCode implements wrapped.StatusCodeResponder.
+func (e *BodyTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// AllowContentType will reject requests with a body whose Content-Type is not one of
+// contentTypes with rest.UnsupportedMediaType. Parameters like charset will be ignored.
+//
+//  r.Use(middleware.AllowContentType("application/json", "application/merge-patch+json"))
+func AllowContentType(contentTypes ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]struct{}, len(contentTypes))
+	for _, ct := range contentTypes {
+		allowed[strings.ToLower(strings.TrimSpace(ct))] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if _, ok := allowed[mediaType]; err != nil || !ok {
+				rest.UnsupportedMediaType(w, r, fmt.Sprintf("Content-Type must be one of: %s", strings.Join(contentTypes, ", ")))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxBodySize will limit the request body to limit bytes.
+//
+// Requests with a larger Content-Length will be rejected with rest.PayloadTooLarge. Otherwise
+// the body will be wrapped in http.MaxBytesReader, reading beyond the limit will return a
+// *BodyTooLargeError which can be passed to rest.Error.
+//
+//  r.With(middleware.MaxBodySize(1 << 20)).Post("/upload", uploadHandler)
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				rest.Error(w, r, &BodyTooLargeError{Limit: limit})
+				return
+			}
+
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit), limit: limit}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireContentLength will reject requests without a Content-Length header, e.g. a
+// chunked body, with rest.LengthRequired.
+func RequireContentLength() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength < 0 {
+				rest.LengthRequired(w, r, "")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitedBody will translate the error of http.MaxBytesReader into a *BodyTooLargeError.
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && !errors.Is(err, io.EOF) && b.read >= b.limit {
+		return n, &BodyTooLargeError{Limit: b.limit}
+	}
+
+	return n, err
+}
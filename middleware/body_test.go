@@ -0,0 +1,139 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+func TestAllowContentType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		"allowed":             {"application/json", "{}", http.StatusOK},
+		"allowed with params": {"application/json; charset=utf-8", "{}", http.StatusOK},
+		"not allowed":         {"text/plain", "{}", http.StatusUnsupportedMediaType},
+		"missing":             {"", "{}", http.StatusUnsupportedMediaType},
+		"no body":             {"", "", http.StatusOK},
+	}
+
+	h := middleware.AllowContentType("application/json")(okHandler())
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("POST", "/unittest", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, tc.code)
+		})
+	}
+}
+
+func TestMaxBodySize_ContentLength(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.MaxBodySize(4)(okHandler())
+
+	req := httptest.NewRequest("POST", "/unittest", strings.NewReader("too large"))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusCode(t, resp, wrap, http.StatusRequestEntityTooLarge)
+}
+
+func TestMaxBodySize_Read(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.MaxBodySize(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v interface{}
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			var tooLarge *middleware.BodyTooLargeError
+			if !errors.As(err, &tooLarge) {
+				t.Errorf(`expected BodyTooLargeError, got: '%s'`, err)
+			}
+			rest.Error(w, r, err)
+			return
+		}
+		rest.Ok(w, r, v)
+	}))
+
+	req := httptest.NewRequest("POST", "/unittest", ioutil.NopCloser(strings.NewReader(`"too large"`)))
+	req.ContentLength = -1
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusCode(t, resp, wrap, http.StatusRequestEntityTooLarge)
+}
+
+func TestMaxBodySize_InLimit(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.MaxBodySize(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			rest.Error(w, r, err)
+			return
+		}
+		rest.Ok(w, r, string(body))
+	}))
+
+	req := httptest.NewRequest("POST", "/unittest", strings.NewReader("1234"))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, rr.Code)
+	}
+}
+
+func TestRequireContentLength(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.RequireContentLength()(okHandler())
+
+	req := httptest.NewRequest("POST", "/unittest", strings.NewReader("chunked"))
+	req.ContentLength = -1
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusCode(t, resp, wrap, http.StatusLengthRequired)
+
+	req = httptest.NewRequest("POST", "/unittest", strings.NewReader("known"))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, rr.Code)
+	}
+}
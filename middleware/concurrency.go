@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+// Priority is used by ConcurrencyLimit to order waiting requests.
+type Priority int
+
+const (
+	// PriorityLow requests will be admitted after all other waiting requests.
+	PriorityLow Priority = -1
+	// PriorityNormal is the default priority.
+	PriorityNormal Priority = 0
+	// PriorityHigh requests will be admitted before PriorityNormal requests.
+	PriorityHigh Priority = 1
+	// PriorityCritical requests will always be admitted and don't count as in-flight
+	// request, e.g. health checks.
+	PriorityCritical Priority = 2
+)
+
+// DefaultShedRetryAfter is used by ConcurrencyLimit if ConcurrencyConfig.RetryAfter is 0.
+const DefaultShedRetryAfter = time.Second
+
+// ErrInvalidConcurrency will be raised by ConcurrencyLimit if MaxInFlight is not positive or
+// MaxQueue is negative.
+var ErrInvalidConcurrency = errors.New("middleware: concurrency limit requires a positive MaxInFlight and a non-negative MaxQueue")
+
+// ConcurrencyConfig is the configuration of ConcurrencyLimit.
+type ConcurrencyConfig struct {
+	// MaxInFlight is the maximum amount of concurrently served requests, it must be positive.
+	MaxInFlight int
+	// MaxQueue is the maximum amount of requests waiting for admission. If the queue
+	// is full, new requests will be shed.
+	MaxQueue int
+	// MaxWait is the maximum time a request waits in the queue. If 0, a request will wait
+	// until its context is done.
+	MaxWait time.Duration
+	// RetryAfter will be sent with shed requests. It defaults to DefaultShedRetryAfter.
+	RetryAfter time.Duration
+	// Message will be sent with shed requests.
+	Message string
+	// Classifier returns the Priority of a request. If nil, all requests have PriorityNormal.
+	Classifier func(r *http.Request) Priority
+}
+
+// ConcurrencyLimit will limit the amount of concurrently served requests. Excess requests
+// will wait in a queue and will be shed with rest.ServiceUnavailableRetryAfter if the queue is
+// full or the request waited too long.
+//
+//  // globally
+//  r.Use(middleware.ConcurrencyLimit(middleware.ConcurrencyConfig{MaxInFlight: 100, MaxQueue: 50, MaxWait: time.Second}))
+//
+//  // per route
+//  r.With(middleware.ConcurrencyLimit(middleware.ConcurrencyConfig{MaxInFlight: 5})).Post("/reports", reportHandler)
+//
+// Every call creates its own limit, so a middleware can be shared by several routes to limit them together.
+// It panics with ErrInvalidConcurrency if the config would shed every request.
+func ConcurrencyLimit(cfg ConcurrencyConfig) func(http.Handler) http.Handler {
+	if cfg.MaxInFlight <= 0 || cfg.MaxQueue < 0 {
+		panic(ErrInvalidConcurrency)
+	}
+	if cfg.RetryAfter == 0 {
+		cfg.RetryAfter = DefaultShedRetryAfter
+	}
+	l := &concurrencyLimiter{cfg: cfg}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			priority := PriorityNormal
+			if cfg.Classifier != nil {
+				priority = cfg.Classifier(r)
+			}
+
+			if priority >= PriorityCritical {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !l.acquire(r, priority) {
+				rest.ServiceUnavailableRetryAfter(w, r, cfg.Message, cfg.RetryAfter)
+				return
+			}
+			defer l.release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type concurrencyWaiter struct {
+	priority Priority
+	ready    chan struct{}
+}
+
+type concurrencyLimiter struct {
+	cfg ConcurrencyConfig
+
+	mu       sync.Mutex
+	inFlight int
+	// waiters are ordered by priority, requests with the same priority are FIFO.
+	waiters []*concurrencyWaiter
+}
+
+func (l *concurrencyLimiter) acquire(r *http.Request, priority Priority) bool {
+	l.mu.Lock()
+	if l.inFlight < l.cfg.MaxInFlight && len(l.waiters) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return true
+	}
+
+	if len(l.waiters) >= l.cfg.MaxQueue {
+		l.mu.Unlock()
+		return false
+	}
+
+	waiter := &concurrencyWaiter{priority: priority, ready: make(chan struct{})}
+	l.enqueue(waiter)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.cfg.MaxWait > 0 {
+		timer := time.NewTimer(l.cfg.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-waiter.ready:
+		return true
+	case <-timeout:
+	case <-r.Context().Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.dequeue(waiter) {
+		// the slot has been handed over in the meantime
+		l.release0()
+	}
+
+	return false
+}
+
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.release0()
+}
+
+// release0 must be called with l.mu held.
+func (l *concurrencyLimiter) release0() {
+	if len(l.waiters) == 0 {
+		l.inFlight--
+		return
+	}
+
+	// hand over the slot to the next waiter
+	waiter := l.waiters[0]
+	l.waiters = l.waiters[1:]
+	close(waiter.ready)
+}
+
+func (l *concurrencyLimiter) enqueue(waiter *concurrencyWaiter) {
+	i := len(l.waiters)
+	for i > 0 && l.waiters[i-1].priority < waiter.priority {
+		i--
+	}
+
+	l.waiters = append(l.waiters, nil)
+	copy(l.waiters[i+1:], l.waiters[i:])
+	l.waiters[i] = waiter
+}
+
+func (l *concurrencyLimiter) dequeue(waiter *concurrencyWaiter) bool {
+	for i, w := range l.waiters {
+		if w == waiter {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+// blockingHandler blocks until release is closed. It signals on started once it is running.
+func blockingHandler(started chan<- string, release <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		started <- r.URL.Path
+		<-release
+		rest.Ok(w, r, nil)
+	}
+}
+
+func TestConcurrencyLimit_Shed(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan string, 1)
+	release := make(chan struct{})
+	h := middleware.ConcurrencyLimit(middleware.ConcurrencyConfig{MaxInFlight: 1, RetryAfter: 2 * time.Second})(blockingHandler(started, release))
+
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/first", nil))
+	<-started
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/second", nil))
+	close(release)
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusCode(t, resp, wrap, http.StatusServiceUnavailable)
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "2" {
+		t.Fatalf(`expected Retry-After to be '2', got: '%s'`, retryAfter)
+	}
+}
+
+func TestConcurrencyLimit_MaxWait(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan string, 1)
+	release := make(chan struct{})
+	defer close(release)
+	h := middleware.ConcurrencyLimit(middleware.ConcurrencyConfig{MaxInFlight: 1, MaxQueue: 1, MaxWait: 10 * time.Millisecond})(blockingHandler(started, release))
+
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/first", nil))
+	<-started
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/second", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestConcurrencyLimit_Priority(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan string, 3)
+	release := make(chan struct{}, 3)
+	classifier := func(r *http.Request) middleware.Priority {
+		switch r.URL.Path {
+		case "/health":
+			return middleware.PriorityCritical
+		case "/high":
+			return middleware.PriorityHigh
+		}
+		return middleware.PriorityNormal
+	}
+	h := middleware.ConcurrencyLimit(middleware.ConcurrencyConfig{MaxInFlight: 1, MaxQueue: 2, Classifier: classifier})(blockingHandler(started, release))
+
+	var wg sync.WaitGroup
+	serve := func(path string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		}()
+	}
+
+	serve("/first")
+	<-started
+
+	serve("/normal")
+	time.Sleep(10 * time.Millisecond)
+	serve("/high")
+	time.Sleep(10 * time.Millisecond)
+
+	// critical requests bypass the limit
+	serve("/health")
+	if path := <-started; path != "/health" {
+		t.Fatalf(`expected '/health' to be admitted, got: '%s'`, path)
+	}
+	release <- struct{}{}
+
+	// release /first, the queued /high must be admitted before /normal
+	release <- struct{}{}
+	if path := <-started; path != "/high" {
+		t.Fatalf(`expected '/high' to be admitted, got: '%s'`, path)
+	}
+	release <- struct{}{}
+	if path := <-started; path != "/normal" {
+		t.Fatalf(`expected '/normal' to be admitted, got: '%s'`, path)
+	}
+	release <- struct{}{}
+
+	wg.Wait()
+}
+
+func TestConcurrencyLimit_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]middleware.ConcurrencyConfig{
+		"zero":           {},
+		"negative":       {MaxInFlight: -1},
+		"negative queue": {MaxInFlight: 1, MaxQueue: -1},
+	}
+
+	for name, cfg := range tests {
+		cfg := cfg
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, middleware.ErrInvalidConcurrency) {
+					t.Fatalf(`expected panic with ErrInvalidConcurrency, got: '%v'`, err)
+				}
+			}()
+
+			middleware.ConcurrencyLimit(cfg)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+// CORSConfig is the configuration of CORS.
+type CORSConfig struct {
+	// AllowedOrigins contains the allowed origins. An origin can contain one "*" as wildcard,
+	// e.g. "https://*.example.com". The single origin "*" allows all origins, it cannot be
+	// combined with AllowCredentials. Use AllowOriginFunc to allow all origins with credentials.
+	AllowedOrigins []string
+	// AllowOriginFunc will be used if the origin is not in AllowedOrigins.
+	AllowOriginFunc func(r *http.Request, origin string) bool
+	// AllowedMethods contains the allowed methods. It defaults to GET, HEAD, POST, PUT, PATCH and DELETE.
+	AllowedMethods []string
+	// AllowedHeaders contains the allowed request headers. "*" allows all headers.
+	// It defaults to Accept, Authorization and Content-Type.
+	AllowedHeaders []string
+	// ExposedHeaders contains the response headers which are accessible by the client.
+	ExposedHeaders []string
+	// AllowCredentials allows requests with cookies or the Authorization header.
+	AllowCredentials bool
+	// MaxAge is the time a preflight response can be cached by the client.
+	MaxAge time.Duration
+}
+
+// CORS implements Cross-Origin Resource Sharing.
+//
+//  r.Use(middleware.CORS(middleware.CORSConfig{
+//      AllowedOrigins:   []string{"https://app.example.com", "https://*.example.dev"},
+//      AllowCredentials: true,
+//      MaxAge:           time.Hour,
+//  }))
+//
+// Preflight requests which are not allowed (origin, method or headers) will be rejected
+// with rest.Forbidden. Allowed preflight requests will be answered with rest.NoContent and
+// won't reach the next handler. Other requests will always be passed to the next handler,
+// the CORS headers will only be set if the origin is allowed.
+//
+// CORS panics if AllowedOrigins contains "*" and AllowCredentials is true, because every
+// website could read authenticated responses.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	c := newCORS(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(w, r)
+				return
+			}
+
+			c.actual(w, r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type cors struct {
+	cfg            CORSConfig
+	allowAll       bool
+	origins        map[string]struct{}
+	patterns       [][2]string
+	methods        map[string]struct{}
+	allowedHeaders map[string]struct{}
+	allHeaders     bool
+}
+
+func newCORS(cfg CORSConfig) *cors {
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = []string{
+			http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+		}
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = []string{"Accept", "Authorization", "Content-Type"}
+	}
+
+	c := &cors{
+		cfg:            cfg,
+		origins:        make(map[string]struct{}),
+		methods:        make(map[string]struct{}),
+		allowedHeaders: make(map[string]struct{}),
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			c.allowAll = true
+		case strings.Contains(origin, "*"):
+			i := strings.Index(origin, "*")
+			c.patterns = append(c.patterns, [2]string{origin[:i], origin[i+1:]})
+		default:
+			c.origins[origin] = struct{}{}
+		}
+	}
+
+	if c.allowAll && cfg.AllowCredentials {
+		panic(`middleware: CORS origin "*" cannot be combined with AllowCredentials`)
+	}
+
+	for _, method := range cfg.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = struct{}{}
+	}
+
+	for _, header := range cfg.AllowedHeaders {
+		if header == "*" {
+			c.allHeaders = true
+		}
+		c.allowedHeaders[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+
+	return c
+}
+
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	if !c.isOriginAllowed(r, origin) {
+		rest.Forbidden(w, r, "origin not allowed")
+		return
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if _, ok := c.methods[method]; !ok {
+		rest.Forbidden(w, r, "method not allowed")
+		return
+	}
+
+	requestHeaders := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+	if !c.areHeadersAllowed(requestHeaders) {
+		rest.Forbidden(w, r, "headers not allowed")
+		return
+	}
+
+	c.setAllowOrigin(w, origin)
+	h.Set("Access-Control-Allow-Methods", method)
+	if len(requestHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+	}
+	if c.cfg.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.cfg.MaxAge.Seconds())))
+	}
+
+	rest.NoContent(w, r)
+}
+
+func (c *cors) actual(w http.ResponseWriter, r *http.Request) {
+	if !c.allowAll {
+		w.Header().Add("Vary", "Origin")
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !c.isOriginAllowed(r, origin) {
+		return
+	}
+
+	c.setAllowOrigin(w, origin)
+	if len(c.cfg.ExposedHeaders) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.cfg.ExposedHeaders, ", "))
+	}
+}
+
+func (c *cors) setAllowOrigin(w http.ResponseWriter, origin string) {
+	if c.allowAll {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if c.cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) isOriginAllowed(r *http.Request, origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if c.allowAll {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+	if _, ok := c.origins[lower]; ok {
+		return true
+	}
+
+	for _, pattern := range c.patterns {
+		if len(lower) > len(pattern[0])+len(pattern[1]) &&
+			strings.HasPrefix(lower, pattern[0]) && strings.HasSuffix(lower, pattern[1]) {
+			return true
+		}
+	}
+
+	return c.cfg.AllowOriginFunc != nil && c.cfg.AllowOriginFunc(r, origin)
+}
+
+func (c *cors) areHeadersAllowed(headers []string) bool {
+	if c.allHeaders {
+		return true
+	}
+
+	for _, header := range headers {
+		if _, ok := c.allowedHeaders[http.CanonicalHeaderKey(header)]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseHeaderList(value string) []string {
+	var headers []string
+	for _, header := range strings.Split(value, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+
+	return headers
+}
@@ -0,0 +1,155 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+func TestCORS_Preflight(t *testing.T) {
+	t.Parallel()
+
+	cfg := middleware.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.dev"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}
+
+	tests := map[string]struct {
+		origin  string
+		method  string
+		headers string
+		code    int
+	}{
+		"allowed":             {"https://app.example.com", "PUT", "Content-Type", http.StatusNoContent},
+		"allowed pattern":     {"https://pr-1.example.dev", "GET", "", http.StatusNoContent},
+		"pattern needs host":  {"https://.example.dev", "GET", "", http.StatusForbidden},
+		"origin not allowed":  {"https://evil.com", "GET", "", http.StatusForbidden},
+		"method not allowed":  {"https://app.example.com", "CONNECT", "", http.StatusForbidden},
+		"header not allowed":  {"https://app.example.com", "GET", "X-Custom", http.StatusForbidden},
+		"origin is lowercase": {"HTTPS://APP.EXAMPLE.COM", "GET", "", http.StatusNoContent},
+	}
+
+	h := middleware.CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error(`expected preflight not to reach the handler`)
+	}))
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("OPTIONS", "/unittest", nil)
+			req.Header.Set("Origin", tc.origin)
+			req.Header.Set("Access-Control-Request-Method", tc.method)
+			if tc.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tc.headers)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.code {
+				t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, tc.code, resp.StatusCode)
+			}
+
+			if tc.code == http.StatusForbidden {
+				wrap := resttest.ParseToWrapped(t, resp.Body)
+				resttest.ExpectStatusCode(t, resp, wrap, http.StatusForbidden)
+				if resp.Header.Get("Access-Control-Allow-Origin") != "" {
+					t.Fatal(`expected no Access-Control-Allow-Origin header`)
+				}
+				return
+			}
+
+			if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != tc.origin {
+				t.Fatalf(`expected Access-Control-Allow-Origin to be '%s', got: '%s'`, tc.origin, origin)
+			}
+			if resp.Header.Get("Access-Control-Allow-Credentials") != "true" {
+				t.Fatal(`expected Access-Control-Allow-Credentials to be 'true'`)
+			}
+			if maxAge := resp.Header.Get("Access-Control-Max-Age"); maxAge != "3600" {
+				t.Fatalf(`expected Access-Control-Max-Age to be '3600', got: '%s'`, maxAge)
+			}
+			vary := resp.Header.Values("Vary")
+			if len(vary) < 3 || vary[0] != "Origin" || vary[1] != "Access-Control-Request-Method" || vary[2] != "Access-Control-Request-Headers" {
+				t.Fatalf(`expected Vary headers of the preflight, got: '%v'`, vary)
+			}
+		})
+	}
+}
+
+func TestCORS_Actual(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.CORS(middleware.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		ExposedHeaders: []string{"RateLimit-Remaining"},
+	})(okHandler())
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "https://app.example.com" {
+		t.Fatalf(`expected Access-Control-Allow-Origin to be 'https://app.example.com', got: '%s'`, origin)
+	}
+	if exposed := rr.Header().Get("Access-Control-Expose-Headers"); exposed != "RateLimit-Remaining" {
+		t.Fatalf(`expected Access-Control-Expose-Headers to be 'RateLimit-Remaining', got: '%s'`, exposed)
+	}
+	if vary := rr.Header().Get("Vary"); vary != "Origin" {
+		t.Fatalf(`expected Vary to be 'Origin', got: '%s'`, vary)
+	}
+
+	req = httptest.NewRequest("GET", "/unittest", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, rr.Code)
+	}
+	if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Fatalf(`expected no Access-Control-Allow-Origin, got: '%s'`, origin)
+	}
+}
+
+func TestCORS_AllowAll(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.CORS(middleware.CORSConfig{AllowedOrigins: []string{"*"}})(okHandler())
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	req.Header.Set("Origin", "https://any.example.com")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Fatalf(`expected Access-Control-Allow-Origin to be '*', got: '%s'`, origin)
+	}
+	for _, vary := range rr.Header().Values("Vary") {
+		if vary == "Origin" {
+			t.Fatal(`expected no Vary header for Origin`)
+		}
+	}
+}
+
+func TestCORS_AllowAllWithCredentials(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if p := recover(); p == nil {
+			t.Fatal(`expected panic for origin "*" with credentials`)
+		}
+	}()
+
+	middleware.CORS(middleware.CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
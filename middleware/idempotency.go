@@ -0,0 +1,299 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+// Defaults of IdempotencyConfig.
+const (
+	DefaultIdempotencyHeader      = "Idempotency-Key"
+	DefaultIdempotencyTTL         = 24 * time.Hour
+	DefaultIdempotencyMaxBodySize = 1 << 20
+)
+
+// IdempotencyRecord is the recorded response of a request with an idempotency key.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the method, path and body of the request.
+	Fingerprint string
+	// Done is false as long as the first request is in-flight.
+	Done bool
+	// Code is the recorded status code.
+	Code int
+	// Header contains the recorded headers.
+	Header http.Header
+	// Body contains the recorded body, e.g. the rendered wrapped.Response.
+	Body []byte
+}
+
+// IdempotencyStore persists IdempotencyRecord.
+//
+// Implement this interface to share the records between multiple instances, e.g. with Redis.
+type IdempotencyStore interface {
+	// Lock will atomically save an in-flight record with fingerprint for key if there is no
+	// record yet and returns true. Otherwise the existing record will be returned.
+	Lock(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error)
+	// Save will replace the in-flight record of key with the completed record.
+	Save(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Unlock will remove the in-flight record of key, so the request can be retried.
+	Unlock(ctx context.Context, key string) error
+}
+
+// IdempotencyConfig is the configuration of Idempotency.
+type IdempotencyConfig struct {
+	// Store persists the records. If nil, a MemoryIdempotencyStore will be used.
+	Store IdempotencyStore
+	// Header contains the idempotency key. It defaults to DefaultIdempotencyHeader.
+	Header string
+	// TTL is the time a record will be kept. It defaults to DefaultIdempotencyTTL.
+	TTL time.Duration
+	// Methods contains the methods which support idempotency keys. It defaults to POST.
+	Methods []string
+	// Scope will be prefixed to the idempotency key to separate the keys of clients, so a
+	// client cannot replay the response of another one. It defaults to KeyByIdentity.
+	Scope KeyFunc
+	// MaxBodySize limits the body which is read to fingerprint the request. Larger bodies will
+	// be rejected with http.StatusRequestEntityTooLarge. It defaults to
+	// DefaultIdempotencyMaxBodySize.
+	MaxBodySize int64
+}
+
+// Idempotency will record the first response of a request with an idempotency key and
+// replays it for retries with the same key.
+//
+//  r.With(middleware.Idempotency(middleware.IdempotencyConfig{})).Post("/payments", createPayment)
+//
+// A replayed response contains the header "Idempotent-Replayed: true". Retries of a request
+// which is still in-flight will be rejected with rest.Conflict. Reusing a key with another
+// method, path or body will be rejected with rest.UnprocessableEntity.
+//
+// Responses with status "fail" (5XX) won't be recorded, so the request can be retried.
+//
+// The keys are scoped by client with KeyByIdentity, so Idempotency should run after the
+// authentication, e.g. JWT or APIKey.
+func Idempotency(cfg IdempotencyConfig) func(http.Handler) http.Handler {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryIdempotencyStore()
+	}
+	if cfg.Header == "" {
+		cfg.Header = DefaultIdempotencyHeader
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = DefaultIdempotencyTTL
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost}
+	}
+	if cfg.Scope == nil {
+		cfg.Scope = KeyByIdentity
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultIdempotencyMaxBodySize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(cfg.Header)
+			if key == "" || !containsString(cfg.Methods, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scope, err := cfg.Scope(r)
+			if err != nil {
+				rest.Error(w, r, err)
+				return
+			}
+			key = scope + ":" + key
+
+			fingerprint, err := requestFingerprint(r, cfg.MaxBodySize)
+			if err != nil {
+				rest.Error(w, r, err)
+				return
+			}
+
+			record, acquired, err := cfg.Store.Lock(r.Context(), key, fingerprint, cfg.TTL)
+			if err != nil {
+				rest.Error(w, r, err)
+				return
+			}
+
+			if !acquired {
+				replay(w, r, record, fingerprint)
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w}
+			completed := false
+			defer func() {
+				if !completed {
+					_ = cfg.Store.Unlock(context.Background(), key)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+
+			if rw.code == 0 {
+				rw.WriteHeader(http.StatusOK)
+			}
+			if rw.code >= http.StatusInternalServerError {
+				return
+			}
+
+			err = cfg.Store.Save(r.Context(), key, &IdempotencyRecord{
+				Fingerprint: fingerprint,
+				Done:        true,
+				Code:        rw.code,
+				Header:      rw.header,
+				Body:        rw.body.Bytes(),
+			}, cfg.TTL)
+			completed = err == nil
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, record *IdempotencyRecord, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		rest.UnprocessableEntity(w, r, "idempotency key has been used for another request")
+		return
+	}
+
+	if !record.Done {
+		rest.Conflict(w, r, "request with this idempotency key is in progress")
+		return
+	}
+
+	for k, v := range record.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(record.Code)
+	_, _ = w.Write(record.Body)
+}
+
+// requestFingerprint returns a hash of the method, path and body of r. The body will be restored.
+// Bodies larger than limit will be rejected with a *BodyTooLargeError.
+func requestFingerprint(r *http.Request, limit int64) (string, error) {
+	h := sha256.New()
+	_, _ = h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+		_ = r.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		if int64(len(body)) > limit {
+			return "", &BodyTooLargeError{Limit: limit}
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		_, _ = h.Write(body)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recordingWriter records the status code, headers and body written to ResponseWriter.
+type recordingWriter struct {
+	http.ResponseWriter
+	code   int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.code == 0 {
+		rw.code = code
+		rw.header = rw.ResponseWriter.Header().Clone()
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	if rw.code == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(p)
+
+	return rw.ResponseWriter.Write(p)
+}
+
+type idempotencyEntry struct {
+	record  IdempotencyRecord
+	expires time.Time
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore which keeps the records in memory.
+// Expired records will be evicted periodically.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+// NewMemoryIdempotencyStore will create a MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]*idempotencyEntry), lastSweep: time.Now()}
+}
+
+// Lock implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Lock(_ context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expires) {
+		record := entry.record
+		return &record, false, nil
+	}
+
+	s.entries[key] = &idempotencyEntry{record: IdempotencyRecord{Fingerprint: fingerprint}, expires: now.Add(ttl)}
+
+	return nil, true, nil
+}
+
+// Save implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Save(_ context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = &idempotencyEntry{record: *record, expires: time.Now().Add(ttl)}
+
+	return nil
+}
+
+// Unlock implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Unlock(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && !entry.record.Done {
+		delete(s.entries, key)
+	}
+
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+func TestIdempotency(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	h := middleware.Idempotency(middleware.IdempotencyConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-Call", string(rune('0'+n)))
+		rest.Created(w, r, n)
+	}))
+
+	do := func(key, body string) *http.Response {
+		req := httptest.NewRequest("POST", "/payments", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(middleware.DefaultIdempotencyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	first := do("key", `{"amount":1}`)
+	defer first.Body.Close()
+	wrap := resttest.ParseToWrapped(t, first.Body)
+	resttest.ExpectStatusCode(t, first, wrap, http.StatusCreated)
+
+	replayed := do("key", `{"amount":1}`)
+	defer replayed.Body.Close()
+	wrap = resttest.ParseToWrapped(t, replayed.Body)
+	resttest.ExpectStatusCode(t, replayed, wrap, http.StatusCreated)
+
+	if wrap.Data != float64(1) {
+		t.Fatalf(`expected replayed Data to be '1', got: '%v'`, wrap.Data)
+	}
+	if replayed.Header.Get("Idempotent-Replayed") != "true" || replayed.Header.Get("X-Call") != "1" {
+		t.Fatalf(`expected replayed headers, got: '%v'`, replayed.Header)
+	}
+
+	other := do("key", `{"amount":2}`)
+	defer other.Body.Close()
+	wrap = resttest.ParseToWrapped(t, other.Body)
+	resttest.ExpectStatusCode(t, other, wrap, http.StatusUnprocessableEntity)
+
+	withoutKey := do("", `{"amount":1}`)
+	defer withoutKey.Body.Close()
+
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf(`expected 2 calls, got: '%d'`, n)
+	}
+}
+
+func TestIdempotency_InFlight(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan string, 1)
+	release := make(chan struct{})
+	h := middleware.Idempotency(middleware.IdempotencyConfig{})(blockingHandler(started, release))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("POST", "/payments", strings.NewReader("{}"))
+		req.Header.Set(middleware.DefaultIdempotencyHeader, "key")
+		return req
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), newRequest())
+		close(done)
+	}()
+	<-started
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, newRequest())
+	close(release)
+	<-done
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusCode(t, resp, wrap, http.StatusConflict)
+}
+
+func TestIdempotency_FailIsNotRecorded(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	h := middleware.Idempotency(middleware.IdempotencyConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			rest.InternalServerError(w, r, "")
+			return
+		}
+		rest.Created(w, r, nil)
+	}))
+
+	for _, code := range []int{http.StatusInternalServerError, http.StatusCreated, http.StatusCreated} {
+		req := httptest.NewRequest("POST", "/payments", strings.NewReader("{}"))
+		req.Header.Set(middleware.DefaultIdempotencyHeader, "key")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != code {
+			t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, code, rr.Code)
+		}
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf(`expected 2 calls, got: '%d'`, n)
+	}
+}
+
+func TestIdempotency_Scope(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	h := middleware.Idempotency(middleware.IdempotencyConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest.Created(w, r, atomic.AddInt32(&calls, 1))
+	}))
+
+	for _, auth := range []string{"Bearer alice", "Bearer bob", "Bearer alice"} {
+		req := httptest.NewRequest("POST", "/payments", strings.NewReader(`{"amount":1}`))
+		req.Header.Set(middleware.DefaultIdempotencyHeader, "key")
+		req.Header.Set("Authorization", auth)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf(`expected Code to be '%d', got: '%d'`, http.StatusCreated, rr.Code)
+		}
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf(`expected 2 calls, got: '%d'`, n)
+	}
+}
+
+func TestIdempotency_MaxBodySize(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.Idempotency(middleware.IdempotencyConfig{MaxBodySize: 4})(okHandler())
+
+	req := httptest.NewRequest("POST", "/payments", strings.NewReader(`{"amount":1}`))
+	req.Header.Set(middleware.DefaultIdempotencyHeader, "key")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusCode(t, resp, wrap, http.StatusRequestEntityTooLarge)
+}
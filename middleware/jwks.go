@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+// JWTKey is a key to verify the signature of a JWT.
+type JWTKey struct {
+	// ID will be matched against the "kid" header of a token. An empty ID matches all tokens.
+	ID string
+	// Algorithm is one of AlgHS256, AlgRS256, AlgES256 or AlgEdDSA.
+	Algorithm string
+	// Key is a []byte for AlgHS256, *rsa.PublicKey for AlgRS256, *ecdsa.PublicKey
+	// for AlgES256 and ed25519.PublicKey for AlgEdDSA.
+	Key interface{}
+}
+
+// JWTKeySet is a static set of keys.
+type JWTKeySet []JWTKey
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS will parse a JSON Web Key Set (RFC 7517).
+//
+// Keys with "use" other than "sig" and unsupported key types will be skipped.
+func ParseJWKS(data []byte) (JWTKeySet, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(JWTKeySet, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.parse()
+		if errors.Is(err, errUnsupportedJWK) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", k.Kid, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// LoadJWKS will read and parse the JSON Web Key Set file at path.
+func LoadJWKS(path string) (JWTKeySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
+
+var errUnsupportedJWK = errors.New("unsupported key")
+
+func (k jwk) parse() (JWTKey, error) {
+	key := JWTKey{ID: k.Kid, Algorithm: k.Alg}
+
+	switch {
+	case k.Kty == "oct" && (k.Alg == "" || k.Alg == AlgHS256):
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return key, err
+		}
+		key.Algorithm, key.Key = AlgHS256, secret
+	case k.Kty == "RSA" && (k.Alg == "" || k.Alg == AlgRS256):
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return key, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return key, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return key, errors.New("invalid exponent")
+		}
+		key.Algorithm, key.Key = AlgRS256, &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case k.Kty == "EC" && k.Crv == "P-256" && (k.Alg == "" || k.Alg == AlgES256):
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return key, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return key, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return key, errors.New("point is not on curve")
+		}
+		key.Algorithm, key.Key = AlgES256, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	case k.Kty == "OKP" && k.Crv == "Ed25519" && (k.Alg == "" || k.Alg == AlgEdDSA):
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return key, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return key, errors.New("invalid key size")
+		}
+		key.Algorithm, key.Key = AlgEdDSA, ed25519.PublicKey(x)
+	default:
+		return key, errUnsupportedJWK
+	}
+
+	return key, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,125 @@
+package middleware_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/middleware"
+)
+
+func jwksJSON(t *testing.T, keys testKeys) []byte {
+	enc := base64.RawURLEncoding.EncodeToString
+
+	data, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "oct", "kid": "hmac", "k": enc(keys.hmac)},
+			{"kty": "RSA", "kid": "rsa", "alg": "RS256", "use": "sig", "n": enc(keys.rsa.N.Bytes()), "e": enc(big.NewInt(int64(keys.rsa.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": enc(keys.ecdsa.X.Bytes()), "y": enc(keys.ecdsa.Y.Bytes())},
+			{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": enc(keys.ed25519.Public().(ed25519.PublicKey))},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+			{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AQAB", "y": "AQAB"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestParseJWKS(t *testing.T) {
+	t.Parallel()
+
+	keys := newTestKeys(t)
+	set, err := middleware.ParseJWKS(jwksJSON(t, keys))
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+
+	if len(set) != 4 {
+		t.Fatalf(`expected 4 keys, got: '%d'`, len(set))
+	}
+
+	expected := map[string]string{
+		"hmac": middleware.AlgHS256,
+		"rsa":  middleware.AlgRS256,
+		"ec":   middleware.AlgES256,
+		"ed":   middleware.AlgEdDSA,
+	}
+	for _, key := range set {
+		if key.Algorithm != expected[key.ID] {
+			t.Fatalf(`expected key '%s' to have algorithm '%s', got: '%s'`, key.ID, expected[key.ID], key.Algorithm)
+		}
+	}
+
+	if pub, ok := set[1].Key.(*rsa.PublicKey); !ok || pub.N.Cmp(keys.rsa.N) != 0 {
+		t.Fatal(`expected rsa key to match`)
+	}
+	if pub, ok := set[2].Key.(*ecdsa.PublicKey); !ok || pub.X.Cmp(keys.ecdsa.X) != 0 {
+		t.Fatal(`expected ecdsa key to match`)
+	}
+}
+
+func TestParseJWKS_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"no json":         `keys`,
+		"ec not on curve": `{"keys":[{"kty":"EC","crv":"P-256","x":"AQAB","y":"AQAB"}]}`,
+		"ed25519 size":    `{"keys":[{"kty":"OKP","crv":"Ed25519","x":"AQAB"}]}`,
+		"rsa empty":       `{"keys":[{"kty":"RSA","n":"","e":"AQAB"}]}`,
+	}
+
+	for name, data := range tests {
+		data := data
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := middleware.ParseJWKS([]byte(data)); err == nil {
+				t.Fatal(`expected error`)
+			}
+		})
+	}
+}
+
+func TestLoadJWKS(t *testing.T) {
+	t.Parallel()
+
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, jwksJSON(t, keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := middleware.LoadJWKS(path)
+	if err != nil {
+		t.Fatalf("did not expect error '%s'", err)
+	}
+
+	h := middleware.JWT(middleware.JWTConfig{Keys: set})(okHandler())
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, keys, middleware.AlgEdDSA, "ed", map[string]interface{}{"exp": time.Now().Unix() + 60}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, rr.Code)
+	}
+
+	if _, err := middleware.LoadJWKS(filepath.Join(os.TempDir(), "does-not-exist.json")); err == nil {
+		t.Fatal(`expected error for missing file`)
+	}
+}
@@ -0,0 +1,353 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+// Supported JWT algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+const ctxKeyClaims = ctxKey("claims")
+
+// Claims contains the claims of a validated JWT.
+type Claims map[string]interface{}
+
+// String returns the claim name as string.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Issuer returns the "iss" claim.
+func (c Claims) Issuer() string {
+	return c.String("iss")
+}
+
+// Audience returns the "aud" claim, which can either be a string or a list of strings.
+func (c Claims) Audience() []string {
+	if aud := c.String("aud"); aud != "" {
+		return []string{aud}
+	}
+
+	return c.Strings("aud")
+}
+
+// Scopes returns the "scope" claim (space separated, RFC 8693) or the "scp" claim (list of strings).
+func (c Claims) Scopes() []string {
+	if scope := c.String("scope"); scope != "" {
+		return strings.Fields(scope)
+	}
+
+	return c.Strings("scp")
+}
+
+// Strings returns the claim name as list of strings.
+func (c Claims) Strings(name string) []string {
+	list, _ := c[name].([]interface{})
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+
+	return strs
+}
+
+// Time returns the NumericDate claim name. The second return value is false if the claim is missing.
+func (c Claims) Time(name string) (time.Time, bool) {
+	switch v := c[name].(type) {
+	case float64:
+		sec, frac := splitFloat(v)
+		return time.Unix(sec, frac), true
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		sec, frac := splitFloat(f)
+		return time.Unix(sec, frac), true
+	}
+
+	return time.Time{}, false
+}
+
+// numericDate returns the NumericDate claim name. It reports errTokenMalformed if the claim
+// is present but no NumericDate.
+func (c Claims) numericDate(name string) (time.Time, bool, error) {
+	if _, ok := c[name]; !ok {
+		return time.Time{}, false, nil
+	}
+
+	t, ok := c.Time(name)
+	if !ok {
+		return time.Time{}, false, errTokenMalformed
+	}
+
+	return t, true, nil
+}
+
+func splitFloat(f float64) (int64, int64) {
+	sec := int64(f)
+	return sec, int64((f - float64(sec)) * float64(time.Second))
+}
+
+// CtxSetClaims will set claims on ctx.
+func CtxSetClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, ctxKeyClaims, claims)
+}
+
+// ClaimsFromCtx will get the claims of JWT on ctx.
+func ClaimsFromCtx(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(ctxKeyClaims).(Claims)
+	return claims, ok
+}
+
+// JWTConfig is the configuration of JWT.
+type JWTConfig struct {
+	// Keys contains the keys to verify the token signature, see ParseJWKS and LoadJWKS.
+	Keys JWTKeySet
+	// Issuer must match the "iss" claim if set.
+	Issuer string
+	// Audience must be contained in the "aud" claim if set.
+	Audience string
+	// ClockSkew is the tolerance for the "exp" and "nbf" claims.
+	ClockSkew time.Duration
+	// Realm will be sent in the WWW-Authenticate header.
+	Realm string
+	// Authorize will be called with the validated claims. If it returns an error, the
+	// request will be rejected with rest.Forbidden.
+	Authorize func(r *http.Request, claims Claims) error
+}
+
+// JWT will validate a JWT from the "Authorization: Bearer" header and sets the claims on
+// the context, see ClaimsFromCtx. The Identity (see IdentityFromCtx) will contain the
+// subject, the scopes and the "roles" claim of the token.
+//
+//  keys, err := middleware.LoadJWKS("jwks.json")
+//  r.Use(middleware.JWT(middleware.JWTConfig{Keys: keys, Issuer: "https://auth.example.com", Audience: "api"}))
+//
+// The signature (HS256, RS256, ES256 or EdDSA) and the "exp", "nbf", "iss" and "aud"
+// claims will be validated. Invalid requests will be rejected with rest.Unauthorized and
+// a WWW-Authenticate header as defined in RFC 6750.
+func JWT(cfg JWTConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", bearerChallenge(cfg.Realm, "", ""))
+				rest.Unauthorized(w, r, "missing bearer token")
+				return
+			}
+
+			claims, err := cfg.validate(token, time.Now())
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", bearerChallenge(cfg.Realm, "invalid_token", err.Error()))
+				rest.Unauthorized(w, r, err.Error())
+				return
+			}
+
+			if cfg.Authorize != nil {
+				if err := cfg.Authorize(r, claims); err != nil {
+					w.Header().Set("WWW-Authenticate", bearerChallenge(cfg.Realm, "insufficient_scope", err.Error()))
+					rest.Forbidden(w, r, err.Error())
+					return
+				}
+			}
+
+			ctx := CtxSetClaims(r.Context(), claims)
+			ctx = CtxSetIdentity(ctx, Identity{ID: claims.Subject(), Scopes: claims.Scopes(), Roles: claims.Strings("roles")})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(auth[7:])
+	return token, token != ""
+}
+
+func bearerChallenge(realm, code, description string) string {
+	params := make([]string, 0, 3)
+	if realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", realm))
+	}
+	if code != "" {
+		params = append(params, fmt.Sprintf("error=%q", code))
+	}
+	if description != "" {
+		params = append(params, fmt.Sprintf("error_description=%q", description))
+	}
+
+	if len(params) == 0 {
+		return "Bearer"
+	}
+
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+var (
+	errTokenMalformed = errors.New("malformed token")
+	errTokenAlgorithm = errors.New("unsupported token algorithm")
+	errTokenKey       = errors.New("no matching key")
+	errTokenSignature = errors.New("invalid token signature")
+	errTokenExpired   = errors.New("token is expired")
+	errTokenNotBefore = errors.New("token is not valid yet")
+	errTokenIssuer    = errors.New("invalid token issuer")
+	errTokenAudience  = errors.New("invalid token audience")
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (cfg *JWTConfig) validate(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errTokenMalformed
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errTokenMalformed
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errTokenMalformed
+	}
+
+	if err := cfg.Keys.verify(header, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil || claims == nil {
+		return nil, errTokenMalformed
+	}
+
+	exp, hasExp, err := claims.numericDate("exp")
+	if err != nil {
+		return nil, err
+	}
+	if hasExp && now.After(exp.Add(cfg.ClockSkew)) {
+		return nil, errTokenExpired
+	}
+
+	nbf, hasNbf, err := claims.numericDate("nbf")
+	if err != nil {
+		return nil, err
+	}
+	if hasNbf && now.Add(cfg.ClockSkew).Before(nbf) {
+		return nil, errTokenNotBefore
+	}
+	if cfg.Issuer != "" && claims.Issuer() != cfg.Issuer {
+		return nil, errTokenIssuer
+	}
+	if cfg.Audience != "" && !containsString(claims.Audience(), cfg.Audience) {
+		return nil, errTokenAudience
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (ks JWTKeySet) verify(header jwtHeader, signingInput string, signature []byte) error {
+	switch header.Alg {
+	case AlgHS256, AlgRS256, AlgES256, AlgEdDSA:
+	default:
+		return errTokenAlgorithm
+	}
+
+	found := false
+	for _, key := range ks {
+		if key.Algorithm != header.Alg || (key.ID != "" && header.Kid != "" && key.ID != header.Kid) {
+			continue
+		}
+
+		found = true
+		if verifySignature(key, signingInput, signature) {
+			return nil
+		}
+	}
+
+	if !found {
+		return errTokenKey
+	}
+
+	return errTokenSignature
+}
+
+func verifySignature(key JWTKey, signingInput string, signature []byte) bool {
+	hash := sha256.Sum256([]byte(signingInput))
+
+	switch k := key.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		_, _ = mac.Write([]byte(signingInput))
+		return key.Algorithm == AlgHS256 && hmac.Equal(mac.Sum(nil), signature)
+	case *rsa.PublicKey:
+		return key.Algorithm == AlgRS256 && rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], signature) == nil
+	case *ecdsa.PublicKey:
+		if key.Algorithm != AlgES256 || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, hash[:], r, s)
+	case ed25519.PublicKey:
+		return key.Algorithm == AlgEdDSA && ed25519.Verify(k, []byte(signingInput), signature)
+	}
+
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
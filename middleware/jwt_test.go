@@ -0,0 +1,191 @@
+package middleware_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+type testKeys struct {
+	hmac    []byte
+	rsa     *rsa.PrivateKey
+	ecdsa   *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKeys{hmac: []byte("secret"), rsa: rsaKey, ecdsa: ecKey, ed25519: edKey}
+}
+
+func (k testKeys) keySet() middleware.JWTKeySet {
+	return middleware.JWTKeySet{
+		{Algorithm: middleware.AlgHS256, Key: k.hmac},
+		{ID: "rsa", Algorithm: middleware.AlgRS256, Key: &k.rsa.PublicKey},
+		{Algorithm: middleware.AlgES256, Key: &k.ecdsa.PublicKey},
+		{Algorithm: middleware.AlgEdDSA, Key: k.ed25519.Public()},
+	}
+}
+
+func signJWT(t *testing.T, keys testKeys, alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch alg {
+	case middleware.AlgHS256:
+		mac := hmac.New(sha256.New, keys.hmac)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case middleware.AlgRS256:
+		s, err := rsa.SignPKCS1v15(rand.Reader, keys.rsa, crypto.SHA256, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = s
+	case middleware.AlgES256:
+		r, s, err := ecdsa.Sign(rand.Reader, keys.ecdsa, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case middleware.AlgEdDSA:
+		sig = ed25519.Sign(keys.ed25519, []byte(input))
+	default:
+		sig = []byte("none")
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWT(t *testing.T) {
+	t.Parallel()
+
+	keys := newTestKeys(t)
+	now := time.Now().Unix()
+	valid := map[string]interface{}{"sub": "user", "iss": "issuer", "aud": []string{"api"}, "exp": now + 60}
+
+	tests := map[string]struct {
+		token string
+		code  int
+		msg   string
+	}{
+		"HS256":          {signJWT(t, keys, middleware.AlgHS256, "", valid), http.StatusOK, ""},
+		"RS256":          {signJWT(t, keys, middleware.AlgRS256, "rsa", valid), http.StatusOK, ""},
+		"ES256":          {signJWT(t, keys, middleware.AlgES256, "", valid), http.StatusOK, ""},
+		"EdDSA":          {signJWT(t, keys, middleware.AlgEdDSA, "", valid), http.StatusOK, ""},
+		"missing":        {"", http.StatusUnauthorized, "missing bearer token"},
+		"malformed":      {"abc", http.StatusUnauthorized, "malformed token"},
+		"alg none":       {signJWT(t, keys, "none", "", valid), http.StatusUnauthorized, "unsupported token algorithm"},
+		"wrong kid":      {signJWT(t, keys, middleware.AlgRS256, "other", valid), http.StatusUnauthorized, "no matching key"},
+		"bad signature":  {signJWT(t, keys, middleware.AlgHS256, "", valid) + "x", http.StatusUnauthorized, "invalid token signature"},
+		"expired":        {signJWT(t, keys, middleware.AlgHS256, "", map[string]interface{}{"iss": "issuer", "aud": "api", "exp": now - 60}), http.StatusUnauthorized, "token is expired"},
+		"expired skewed": {signJWT(t, keys, middleware.AlgHS256, "", map[string]interface{}{"iss": "issuer", "aud": "api", "exp": now - 5}), http.StatusOK, ""},
+		"invalid exp":    {signJWT(t, keys, middleware.AlgHS256, "", map[string]interface{}{"iss": "issuer", "aud": "api", "exp": "tomorrow"}), http.StatusUnauthorized, "malformed token"},
+		"invalid nbf":    {signJWT(t, keys, middleware.AlgHS256, "", map[string]interface{}{"iss": "issuer", "aud": "api", "exp": now + 60, "nbf": nil}), http.StatusUnauthorized, "malformed token"},
+		"not before":     {signJWT(t, keys, middleware.AlgHS256, "", map[string]interface{}{"iss": "issuer", "aud": "api", "nbf": now + 60}), http.StatusUnauthorized, "token is not valid yet"},
+		"wrong issuer":   {signJWT(t, keys, middleware.AlgHS256, "", map[string]interface{}{"iss": "other", "aud": "api"}), http.StatusUnauthorized, "invalid token issuer"},
+		"wrong audience": {signJWT(t, keys, middleware.AlgHS256, "", map[string]interface{}{"iss": "issuer", "aud": "other"}), http.StatusUnauthorized, "invalid token audience"},
+		"forbidden":      {signJWT(t, keys, middleware.AlgHS256, "", map[string]interface{}{"iss": "issuer", "aud": "api", "sub": "blocked"}), http.StatusForbidden, "blocked"},
+	}
+
+	h := middleware.JWT(middleware.JWTConfig{
+		Keys:      keys.keySet(),
+		Issuer:    "issuer",
+		Audience:  "api",
+		ClockSkew: 10 * time.Second,
+		Realm:     "api",
+		Authorize: func(r *http.Request, claims middleware.Claims) error {
+			if claims.Subject() == "blocked" {
+				return errors.New("blocked")
+			}
+			return nil
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := middleware.ClaimsFromCtx(r.Context())
+		if !ok {
+			t.Error(`expected claims in context`)
+		}
+		rest.Ok(w, r, claims.Issuer())
+	}))
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/unittest", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, tc.code)
+
+			if tc.code == http.StatusOK {
+				return
+			}
+
+			resttest.ExpectMessage(t, wrap, tc.msg)
+
+			challenge := resp.Header.Get("WWW-Authenticate")
+			if !strings.HasPrefix(challenge, `Bearer realm="api"`) {
+				t.Fatalf(`expected WWW-Authenticate challenge, got: '%s'`, challenge)
+			}
+		})
+	}
+}
+
+func TestJWT_Challenge(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.JWT(middleware.JWTConfig{})(okHandler())
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	expected := `Bearer error="invalid_token", error_description="malformed token"`
+	if challenge := rr.Header().Get("WWW-Authenticate"); challenge != expected {
+		t.Fatalf(`expected WWW-Authenticate to be '%s', got: '%s'`, expected, challenge)
+	}
+}
@@ -4,9 +4,12 @@
 import (
 	"net/http"
 
+	"github.com/lanz-dev/go-rest/rest"
 	"github.com/lanz-dev/go-rest/wrapped"
 )
 
+type ctxKey string
+
 // ShowError will allow wrapped.Response to show details in "message" for an error.
 //
 //  wrapped.Response{Code: http.StatusInternalServerError, Err: errors.New("myErrorMsg")}
@@ -28,3 +31,17 @@ func ShowError(showError bool) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// SparseFields will allow clients to select the fields of Data with the "fields" query parameter.
+//
+//  GET /users?fields=id,owner.name
+//
+// See rest.SelectFields for the syntax and rest.FieldSelector to whitelist selectable fields.
+// Unknown fields will be rejected with http.StatusBadRequest.
+func SparseFields() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(rest.CtxSetSparseFields(r.Context(), true)))
+		})
+	}
+}
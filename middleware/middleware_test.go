@@ -6,6 +6,7 @@
 	"testing"
 
 	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/rest"
 	"github.com/lanz-dev/go-rest/wrapped"
 )
 
@@ -23,3 +24,18 @@ func TestShowError(t *testing.T) {
 		}
 	}()).ServeHTTP(rr, req)
 }
+
+func TestSparseFields(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	rr := httptest.NewRecorder()
+
+	middleware.SparseFields()(func() http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !rest.SparseFieldsFromCtx(r.Context()) {
+				t.Fatal(`expected ctxKeySparseFields to be true`)
+			}
+		}
+	}()).ServeHTTP(rr, req)
+}
@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+// KeyFunc returns the key a request will be rate limited by.
+type KeyFunc func(r *http.Request) (string, error)
+
+// KeyByIP will use the client ip of http.Request.RemoteAddr as key.
+//
+// Notice: If the service runs behind a proxy, RemoteAddr should be replaced by the
+// real client ip before (e.g. with chi's RealIP middleware).
+func KeyByIP(r *http.Request) (string, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr, nil
+	}
+
+	return host, nil
+}
+
+// KeyByHeader will use the value of header as key, e.g. an api key.
+//
+// Requests without the header will share the empty key.
+func KeyByHeader(header string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		return r.Header.Get(header), nil
+	}
+}
+
+// KeyByIdentity will use the ID of the Identity of IdentityFromCtx as key. Without Identity,
+// a hash of the Authorization header or the client ip of KeyByIP will be used.
+func KeyByIdentity(r *http.Request) (string, error) {
+	if identity, ok := IdentityFromCtx(r.Context()); ok && identity.ID != "" {
+		return "identity:" + identity.ID, nil
+	}
+
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		return "authorization:" + hex.EncodeToString(sum[:]), nil
+	}
+
+	ip, err := KeyByIP(r)
+	return "ip:" + ip, err
+}
+
+// Limiter decides if a request for key is allowed.
+type Limiter interface {
+	// Allow will consume quota for key and reports if the request is allowed.
+	Allow(ctx context.Context, key string) (rest.RateLimit, bool, error)
+}
+
+// RateLimit will limit requests with limiter. The requests will be grouped by key.
+//
+//  // 100 requests per minute and client ip
+//  r.Use(middleware.RateLimit(&middleware.TokenBucket{Limit: 100, Period: time.Minute}, middleware.KeyByIP))
+//
+// Every response contains the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset header.
+// Rejected requests will be answered with rest.TooManyRequestsRateLimit.
+func RateLimit(limiter Limiter, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k, err := key(r)
+			if err != nil {
+				rest.Error(w, r, err)
+				return
+			}
+
+			limit, ok, err := limiter.Allow(r.Context(), k)
+			if err != nil {
+				rest.Error(w, r, err)
+				return
+			}
+
+			if !ok {
+				rest.TooManyRequestsRateLimit(w, r, "", limit)
+				return
+			}
+
+			rest.SetRateLimit(w, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ErrInvalidLimiter will be returned by TokenBucket and SlidingWindow if the limit or the
+// period is not positive.
+var ErrInvalidLimiter = errors.New("middleware: limiter requires a positive limit and period")
+
+// TokenBucket is a Limiter which refills Limit tokens per Period. Every request consumes
+// one token. Up to Burst tokens can be saved, Burst defaults to Limit.
+//
+// If Store is nil, a MemoryLimiterStore will be used.
+type TokenBucket struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+	Store  LimiterStore
+
+	once sync.Once
+}
+
+// Allow implements Limiter.
+func (tb *TokenBucket) Allow(ctx context.Context, key string) (rest.RateLimit, bool, error) {
+	tb.once.Do(func() {
+		if tb.Store == nil {
+			tb.Store = NewMemoryLimiterStore(0)
+		}
+	})
+	if tb.Limit <= 0 || tb.Period <= 0 {
+		return rest.RateLimit{}, false, ErrInvalidLimiter
+	}
+
+	burst := float64(tb.Burst)
+	if burst <= 0 {
+		burst = float64(tb.Limit)
+	}
+	perToken := tb.Period / time.Duration(tb.Limit)
+	ttl := time.Duration(burst) * perToken
+
+	var (
+		limit   = rest.RateLimit{Limit: int(burst)}
+		allowed bool
+	)
+	err := tb.Store.Update(ctx, key, ttl, func(state *LimiterState) {
+		now := time.Now()
+		if state.Time.IsZero() {
+			state.Tokens = burst
+		} else {
+			state.Tokens = math.Min(burst, state.Tokens+float64(now.Sub(state.Time))/float64(perToken))
+		}
+		state.Time = now
+
+		if state.Tokens >= 1 {
+			state.Tokens--
+			allowed = true
+			limit.Reset = time.Duration((burst - state.Tokens) * float64(perToken))
+		} else {
+			limit.Reset = time.Duration((1 - state.Tokens) * float64(perToken))
+		}
+		limit.Remaining = int(state.Tokens)
+	})
+
+	return limit, allowed, err
+}
+
+// SlidingWindow is a Limiter which allows Limit requests per Window. The count of the
+// previous window will be weighted into the current window to smooth bursts at the
+// window boundaries.
+//
+// If Store is nil, a MemoryLimiterStore will be used.
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+	Store  LimiterStore
+
+	once sync.Once
+}
+
+// Allow implements Limiter.
+func (sw *SlidingWindow) Allow(ctx context.Context, key string) (rest.RateLimit, bool, error) {
+	sw.once.Do(func() {
+		if sw.Store == nil {
+			sw.Store = NewMemoryLimiterStore(0)
+		}
+	})
+	if sw.Limit <= 0 || sw.Window <= 0 {
+		return rest.RateLimit{}, false, ErrInvalidLimiter
+	}
+
+	var (
+		limit   = rest.RateLimit{Limit: sw.Limit}
+		allowed bool
+	)
+	err := sw.Store.Update(ctx, key, 2*sw.Window, func(state *LimiterState) {
+		now := time.Now()
+		start := now.Truncate(sw.Window)
+		switch {
+		case state.Time.Equal(start):
+		case state.Time.Add(sw.Window).Equal(start):
+			state.PrevCount, state.Count = state.Count, 0
+		default:
+			state.PrevCount, state.Count = 0, 0
+		}
+		state.Time = start
+
+		elapsed := now.Sub(start)
+		weight := 1 - float64(elapsed)/float64(sw.Window)
+		count := int(math.Floor(float64(state.PrevCount)*weight)) + state.Count
+
+		if count < sw.Limit {
+			state.Count++
+			count++
+			allowed = true
+		}
+
+		limit.Remaining = sw.Limit - count
+		limit.Reset = sw.Window - elapsed
+	})
+
+	return limit, allowed, err
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// LimiterState is the persisted state of a single key of a Limiter.
+type LimiterState struct {
+	// Tokens contains the available tokens of a TokenBucket.
+	Tokens float64 `json:"tokens,omitempty"`
+	// Count contains the requests in the current window of a SlidingWindow.
+	Count int `json:"count,omitempty"`
+	// PrevCount contains the requests in the previous window of a SlidingWindow.
+	PrevCount int `json:"prevCount,omitempty"`
+	// Time contains the last refill of a TokenBucket or the window start of a SlidingWindow.
+	Time time.Time `json:"time"`
+}
+
+// LimiterStore persists LimiterState.
+//
+// Implement this interface to share the state between multiple instances, e.g. with Redis.
+type LimiterStore interface {
+	// Update will atomically load the state of key, call fn with it and save the modified
+	// state. A missing state will be passed as zero value. The state may be evicted
+	// after it hasn't been updated for ttl.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state *LimiterState)) error
+}
+
+type limiterEntry struct {
+	key     string
+	state   LimiterState
+	expires time.Time
+	// index is the position in limiterHeap.
+	index int
+}
+
+// limiterHeap is a min-heap of entries ordered by expiry.
+type limiterHeap []*limiterEntry
+
+func (h limiterHeap) Len() int           { return len(h) }
+func (h limiterHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+
+func (h limiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *limiterHeap) Push(x interface{}) {
+	entry, _ := x.(*limiterEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *limiterHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+
+	return entry
+}
+
+// MemoryLimiterStore is a LimiterStore which keeps the state in memory.
+//
+// Expired states will be evicted on access. If maxKeys is reached, the state which
+// expires first will be evicted.
+type MemoryLimiterStore struct {
+	mu      sync.Mutex
+	entries map[string]*limiterEntry
+	expiry  limiterHeap
+	maxKeys int
+}
+
+// DefaultMaxLimiterKeys is used by NewMemoryLimiterStore if maxKeys is 0.
+const DefaultMaxLimiterKeys = 100000
+
+// NewMemoryLimiterStore will create a MemoryLimiterStore which holds up to maxKeys states.
+func NewMemoryLimiterStore(maxKeys int) *MemoryLimiterStore {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxLimiterKeys
+	}
+
+	return &MemoryLimiterStore{
+		entries: make(map[string]*limiterEntry),
+		maxKeys: maxKeys,
+	}
+}
+
+// Update implements LimiterStore.
+func (s *MemoryLimiterStore) Update(_ context.Context, key string, ttl time.Duration, fn func(state *LimiterState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.evict(now)
+
+	entry, ok := s.entries[key]
+	if !ok {
+		if len(s.entries) >= s.maxKeys {
+			s.remove(s.expiry[0])
+		}
+		entry = &limiterEntry{key: key}
+		s.entries[key] = entry
+		heap.Push(&s.expiry, entry)
+	}
+
+	fn(&entry.state)
+	entry.expires = now.Add(ttl)
+	heap.Fix(&s.expiry, entry.index)
+
+	return nil
+}
+
+// Len returns the amount of stored states.
+func (s *MemoryLimiterStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// evict removes the expired states.
+func (s *MemoryLimiterStore) evict(now time.Time) {
+	for len(s.expiry) > 0 && now.After(s.expiry[0].expires) {
+		s.remove(s.expiry[0])
+	}
+}
+
+func (s *MemoryLimiterStore) remove(entry *limiterEntry) {
+	heap.Remove(&s.expiry, entry.index)
+	delete(s.entries, entry.key)
+}
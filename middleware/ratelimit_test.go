@@ -0,0 +1,237 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+func okHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest.Ok(w, r, nil)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	limiters := map[string]middleware.Limiter{
+		"token bucket":   &middleware.TokenBucket{Limit: 2, Period: time.Hour},
+		"sliding window": &middleware.SlidingWindow{Limit: 2, Window: time.Hour},
+	}
+
+	for name, limiter := range limiters {
+		limiter := limiter
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := middleware.RateLimit(limiter, middleware.KeyByIP)(okHandler())
+
+			for i, code := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+				req := httptest.NewRequest("GET", "/unittest", nil)
+				rr := httptest.NewRecorder()
+				h.ServeHTTP(rr, req)
+
+				resp := rr.Result()
+				wrap := resttest.ParseToWrapped(t, resp.Body)
+				resp.Body.Close()
+				resttest.ExpectStatusCode(t, resp, wrap, code)
+
+				if limit := resp.Header.Get("RateLimit-Limit"); limit != "2" {
+					t.Fatalf(`expected RateLimit-Limit to be '2', got: '%s'`, limit)
+				}
+				if remaining, expected := resp.Header.Get("RateLimit-Remaining"), []string{"1", "0", "0"}[i]; remaining != expected {
+					t.Fatalf(`expected RateLimit-Remaining to be '%s', got: '%s'`, expected, remaining)
+				}
+				if code == http.StatusTooManyRequests && resp.Header.Get("Retry-After") == "" {
+					t.Fatal(`expected Retry-After header`)
+				}
+			}
+
+			// another client has its own quota
+			req := httptest.NewRequest("GET", "/unittest", nil)
+			req.RemoteAddr = "192.0.2.2:1234"
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRateLimit_KeyByHeader(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.RateLimit(&middleware.TokenBucket{Limit: 1, Period: time.Hour}, middleware.KeyByHeader("X-Api-Key"))(okHandler())
+
+	for _, key := range []string{"a", "b"} {
+		req := httptest.NewRequest("GET", "/unittest", nil)
+		req.Header.Set("X-Api-Key", key)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusOK, rr.Code)
+		}
+	}
+}
+
+func TestKeyByIdentity(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		identity *middleware.Identity
+		auth     string
+		want     string
+	}{
+		"identity":      {&middleware.Identity{ID: "alice"}, "Bearer token", "identity:alice"},
+		"authorization": {nil, "Bearer token", "authorization:b22ac30e61f624d5d9ecfaec62edc932976e15d2f1599809297f5422ed3b396b"},
+		"ip":            {nil, "", "ip:192.0.2.1"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/unittest", nil)
+			if tc.identity != nil {
+				req = req.WithContext(middleware.CtxSetIdentity(req.Context(), *tc.identity))
+			}
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+
+			key, err := middleware.KeyByIdentity(req)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			if key != tc.want {
+				t.Fatalf(`expected key '%s', got: '%s'`, tc.want, key)
+			}
+		})
+	}
+}
+
+func TestRateLimit_KeyError(t *testing.T) {
+	t.Parallel()
+
+	key := func(r *http.Request) (string, error) {
+		return "", errors.New("unittest")
+	}
+	h := middleware.RateLimit(&middleware.TokenBucket{Limit: 1, Period: time.Hour}, key)(okHandler())
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, http.StatusInternalServerError, rr.Code)
+	}
+}
+
+func TestTokenBucket_Refill(t *testing.T) {
+	t.Parallel()
+
+	tb := &middleware.TokenBucket{Limit: 1, Period: 20 * time.Millisecond}
+
+	if _, ok, _ := tb.Allow(context.Background(), "k"); !ok {
+		t.Fatal(`expected first request to be allowed`)
+	}
+	if _, ok, _ := tb.Allow(context.Background(), "k"); ok {
+		t.Fatal(`expected second request to be rejected`)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok, _ := tb.Allow(context.Background(), "k"); !ok {
+		t.Fatal(`expected request after refill to be allowed`)
+	}
+}
+
+func TestMemoryLimiterStore_Evict(t *testing.T) {
+	t.Parallel()
+
+	store := middleware.NewMemoryLimiterStore(2)
+	for _, key := range []string{"a", "b", "c"} {
+		_ = store.Update(context.Background(), key, time.Hour, func(state *middleware.LimiterState) {
+			state.Count++
+		})
+	}
+
+	if n := store.Len(); n != 2 {
+		t.Fatalf(`expected 2 keys, got: '%d'`, n)
+	}
+
+	var count int
+	_ = store.Update(context.Background(), "c", time.Hour, func(state *middleware.LimiterState) {
+		count = state.Count
+	})
+	if count != 1 {
+		t.Fatalf(`expected state of 'c' to be kept, got count: '%d'`, count)
+	}
+}
+
+func TestMemoryLimiterStore_EvictExpired(t *testing.T) {
+	t.Parallel()
+
+	count := func(state *middleware.LimiterState) { state.Count++ }
+
+	store := middleware.NewMemoryLimiterStore(3)
+	_ = store.Update(context.Background(), "short", time.Millisecond, count)
+	_ = store.Update(context.Background(), "a", time.Hour, count)
+	_ = store.Update(context.Background(), "b", 2*time.Hour, count)
+
+	time.Sleep(5 * time.Millisecond)
+	_ = store.Update(context.Background(), "c", time.Hour, count)
+	if n := store.Len(); n != 3 {
+		t.Fatalf(`expected expired key to be evicted, got %d keys`, n)
+	}
+
+	// the store is full, "a" expires first
+	_ = store.Update(context.Background(), "d", time.Hour, count)
+	if n := store.Len(); n != 3 {
+		t.Fatalf(`expected 3 keys, got: '%d'`, n)
+	}
+
+	for key, want := range map[string]int{"b": 1, "a": 0} {
+		var got int
+		_ = store.Update(context.Background(), key, 3*time.Hour, func(state *middleware.LimiterState) {
+			got = state.Count
+		})
+		if got != want {
+			t.Fatalf(`expected count of '%s' to be %d, got: '%d'`, key, want, got)
+		}
+	}
+}
+
+func TestLimiter_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]middleware.Limiter{
+		"token bucket without limit":    &middleware.TokenBucket{Period: time.Minute},
+		"token bucket without period":   &middleware.TokenBucket{Limit: 10},
+		"sliding window without limit":  &middleware.SlidingWindow{Window: time.Minute},
+		"sliding window without window": &middleware.SlidingWindow{Limit: 10},
+	}
+
+	for name, limiter := range tests {
+		limiter := limiter
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, ok, err := limiter.Allow(context.Background(), "k"); ok || !errors.Is(err, middleware.ErrInvalidLimiter) {
+				t.Fatalf(`expected ErrInvalidLimiter, got: '%v'`, err)
+			}
+		})
+	}
+}
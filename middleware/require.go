@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// Policy decides if an Identity is authorized for a request.
+type Policy interface {
+	// Missing returns the missing permissions of identity for r. An empty result grants access.
+	Missing(r *http.Request, identity Identity) []string
+}
+
+// PolicyFunc is an adapter to use a function as Policy.
+type PolicyFunc func(r *http.Request, identity Identity) []string
+
+// Missing implements Policy.
+func (fn PolicyFunc) Missing(r *http.Request, identity Identity) []string {
+	return fn(r, identity)
+}
+
+// Scopes requires all scopes.
+func Scopes(scopes ...string) Policy {
+	return PolicyFunc(func(_ *http.Request, identity Identity) []string {
+		var missing []string
+		for _, scope := range scopes {
+			if !identity.HasScope(scope) {
+				missing = append(missing, scope)
+			}
+		}
+		return missing
+	})
+}
+
+// AnyScope requires at least one of scopes.
+func AnyScope(scopes ...string) Policy {
+	policies := make([]Policy, 0, len(scopes))
+	for _, scope := range scopes {
+		policies = append(policies, Scopes(scope))
+	}
+
+	return Any(policies...)
+}
+
+// Roles requires all roles, see RoleHierarchy to inherit roles.
+func Roles(roles ...string) Policy {
+	return RoleHierarchy(nil).Require(roles...)
+}
+
+// RoleHierarchy maps a role to the roles it inherits.
+//
+//  h := middleware.RoleHierarchy{"admin": {"editor"}, "editor": {"viewer"}}
+//  h.Require("viewer") // grants access to admin, editor and viewer
+type RoleHierarchy map[string][]string
+
+// Require requires all roles. A role is granted if the Identity has it or inherits it.
+func (h RoleHierarchy) Require(roles ...string) Policy {
+	return PolicyFunc(func(_ *http.Request, identity Identity) []string {
+		granted := h.expand(identity.Roles)
+
+		var missing []string
+		for _, role := range roles {
+			if _, ok := granted[role]; !ok {
+				missing = append(missing, "role:"+role)
+			}
+		}
+		return missing
+	})
+}
+
+func (h RoleHierarchy) expand(roles []string) map[string]struct{} {
+	granted := make(map[string]struct{}, len(roles))
+
+	queue := append([]string(nil), roles...)
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+		if _, ok := granted[role]; ok {
+			continue
+		}
+
+		granted[role] = struct{}{}
+		queue = append(queue, h[role]...)
+	}
+
+	return granted
+}
+
+// All requires all policies (AND).
+func All(policies ...Policy) Policy {
+	return PolicyFunc(func(r *http.Request, identity Identity) []string {
+		var missing []string
+		for _, policy := range policies {
+			missing = append(missing, policy.Missing(r, identity)...)
+		}
+		return missing
+	})
+}
+
+// Any requires at least one of policies (OR). The missing permissions will be reported as
+// one expression, e.g. "a and b or role:admin". Without policies access will be denied with
+// the missing permission "<no alternatives>".
+func Any(policies ...Policy) Policy {
+	return PolicyFunc(func(r *http.Request, identity Identity) []string {
+		alternatives := make([]string, 0, len(policies))
+		for _, policy := range policies {
+			missing := policy.Missing(r, identity)
+			if len(missing) == 0 {
+				return nil
+			}
+			alternatives = append(alternatives, strings.Join(missing, " and "))
+		}
+
+		if len(alternatives) == 0 {
+			return []string{"<no alternatives>"}
+		}
+
+		return []string{strings.Join(alternatives, " or ")}
+	})
+}
+
+// Methods selects the Policy by the request method. Methods without a rule will use fallback,
+// a nil fallback grants access.
+//
+//  middleware.Methods(map[string]middleware.Policy{
+//      http.MethodGet:    middleware.Scopes("invoices:read"),
+//      http.MethodPost:   middleware.Scopes("invoices:write"),
+//      http.MethodDelete: middleware.Roles("admin"),
+//  }, nil)
+func Methods(rules map[string]Policy, fallback Policy) Policy {
+	return PolicyFunc(func(r *http.Request, identity Identity) []string {
+		policy, ok := rules[r.Method]
+		if !ok {
+			policy = fallback
+		}
+		if policy == nil {
+			return nil
+		}
+		return policy.Missing(r, identity)
+	})
+}
+
+// MissingPermissions will be set as Data on the wrapped.Response of Require if
+// wrapped.ShowErrorFromCtx is true.
+type MissingPermissions struct {
+	Missing []string `json:"missing"`
+}
+
+// Require will authorize the Identity (see IdentityFromCtx) of a request with policy.
+//
+//  r.With(middleware.Require(middleware.Any(middleware.Scopes("invoices:write"), middleware.Roles("admin")))).Post("/invoices", create)
+//
+// Requests without Identity will be rejected with rest.Unauthorized. Requests which don't
+// satisfy policy will be rejected with http.StatusForbidden. If wrapped.ShowErrorFromCtx is
+// true, Data will contain MissingPermissions.
+func Require(policy Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, ok := IdentityFromCtx(r.Context())
+			if !ok {
+				rest.Unauthorized(w, r, "")
+				return
+			}
+
+			missing := policy.Missing(r, identity)
+			if len(missing) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res := &wrapped.Response{Code: http.StatusForbidden, Message: "insufficient permissions"}
+			if wrapped.ShowErrorFromCtx(r.Context()) {
+				res.Data = MissingPermissions{Missing: missing}
+			}
+			rest.Render(w, r, res)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/resttest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+func TestPolicies(t *testing.T) {
+	t.Parallel()
+
+	hierarchy := middleware.RoleHierarchy{"admin": {"editor"}, "editor": {"viewer"}}
+	identity := middleware.Identity{ID: "unittest", Scopes: []string{"read", "write"}, Roles: []string{"editor"}}
+
+	tests := map[string]struct {
+		policy  middleware.Policy
+		method  string
+		missing []string
+	}{
+		"scopes granted":        {middleware.Scopes("read", "write"), "GET", nil},
+		"scopes missing":        {middleware.Scopes("read", "delete", "admin"), "GET", []string{"delete", "admin"}},
+		"any scope granted":     {middleware.AnyScope("delete", "write"), "GET", nil},
+		"any scope missing":     {middleware.AnyScope("delete", "admin"), "GET", []string{"delete or admin"}},
+		"role granted":          {middleware.Roles("editor"), "GET", nil},
+		"role missing":          {middleware.Roles("viewer"), "GET", []string{"role:viewer"}},
+		"inherited role":        {hierarchy.Require("viewer"), "GET", nil},
+		"inherited role higher": {hierarchy.Require("admin"), "GET", []string{"role:admin"}},
+		"all":                   {middleware.All(middleware.Scopes("read"), middleware.Roles("admin")), "GET", []string{"role:admin"}},
+		"any":                   {middleware.Any(middleware.Scopes("read", "delete"), middleware.Roles("admin")), "GET", []string{"delete or role:admin"}},
+		"any granted":           {middleware.Any(middleware.Scopes("delete"), middleware.Roles("editor")), "GET", nil},
+		"any empty":             {middleware.Any(), "GET", []string{"<no alternatives>"}},
+		"any scope empty":       {middleware.AnyScope(), "GET", []string{"<no alternatives>"}},
+		"method rule":           {middleware.Methods(map[string]middleware.Policy{"DELETE": middleware.Scopes("delete")}, nil), "DELETE", []string{"delete"}},
+		"method fallback nil":   {middleware.Methods(map[string]middleware.Policy{"DELETE": middleware.Scopes("delete")}, nil), "GET", nil},
+		"method fallback":       {middleware.Methods(nil, middleware.Scopes("admin")), "GET", []string{"admin"}},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tc.method, "/unittest", nil)
+			if missing := tc.policy.Missing(req, identity); !reflect.DeepEqual(missing, tc.missing) {
+				t.Fatalf(`expected missing to be '%v', got: '%v'`, tc.missing, missing)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		identity  *middleware.Identity
+		showError bool
+		code      int
+		data      interface{}
+	}{
+		"granted":       {&middleware.Identity{Scopes: []string{"read"}}, false, http.StatusOK, nil},
+		"no identity":   {nil, false, http.StatusUnauthorized, nil},
+		"forbidden":     {&middleware.Identity{}, false, http.StatusForbidden, nil},
+		"forbidden dev": {&middleware.Identity{}, true, http.StatusForbidden, map[string]interface{}{"missing": []interface{}{"read"}}},
+	}
+
+	h := middleware.Require(middleware.Scopes("read"))(okHandler())
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/unittest", nil)
+			ctx := wrapped.CtxSetShowError(req.Context(), tc.showError)
+			if tc.identity != nil {
+				ctx = middleware.CtxSetIdentity(ctx, *tc.identity)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req.WithContext(ctx))
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, tc.code)
+
+			if !reflect.DeepEqual(wrap.Data, tc.data) {
+				t.Fatalf(`expected Data to be '%v', got: '%v'`, tc.data, wrap.Data)
+			}
+		})
+	}
+}
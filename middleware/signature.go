@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+// Defaults of SignatureConfig.
+const (
+	DefaultSignatureHeader          = "X-Signature"
+	DefaultSignatureTimestampHeader = "X-Signature-Timestamp"
+	DefaultSignatureTolerance       = 5 * time.Minute
+	DefaultSignatureMaxBodySize     = 1 << 20
+)
+
+// SignatureConfig is the configuration of VerifySignature.
+type SignatureConfig struct {
+	// Secrets contains the active secrets. A signature is valid if it matches any of them,
+	// which allows to rotate secrets.
+	Secrets [][]byte
+	// Header contains the hex encoded signature, optionally prefixed with the algorithm
+	// (e.g. "sha256=..."). Multiple signatures can be separated by comma. It defaults
+	// to DefaultSignatureHeader.
+	Header string
+	// TimestampHeader contains the unix timestamp of the signature. It defaults to
+	// DefaultSignatureTimestampHeader.
+	TimestampHeader string
+	// Tolerance is the maximum age of a signature. It defaults to DefaultSignatureTolerance.
+	Tolerance time.Duration
+	// Hash is used for the HMAC. It defaults to sha256.New.
+	Hash func() hash.Hash
+	// MaxBodySize limits the body which is read to verify the signature. Larger bodies will be
+	// rejected with http.StatusRequestEntityTooLarge. It defaults to DefaultSignatureMaxBodySize.
+	MaxBodySize int64
+}
+
+// VerifySignature will verify the HMAC signature of incoming webhooks.
+//
+// The signature is calculated over the timestamp, a dot and the raw body:
+//
+//  hex(hmac(secret, timestamp + "." + body))
+//
+// Requests with a missing or invalid signature or a timestamp outside of the tolerance
+// (to prevent replay attacks) will be rejected with rest.Unauthorized. The message
+// describes why the verification failed. Bodies larger than MaxBodySize will be rejected with
+// http.StatusRequestEntityTooLarge before the signature is verified. The body can be read again by the
+// next handler.
+func VerifySignature(cfg SignatureConfig) func(http.Handler) http.Handler {
+	if cfg.Header == "" {
+		cfg.Header = DefaultSignatureHeader
+	}
+	if cfg.TimestampHeader == "" {
+		cfg.TimestampHeader = DefaultSignatureTimestampHeader
+	}
+	if cfg.Tolerance == 0 {
+		cfg.Tolerance = DefaultSignatureTolerance
+	}
+	if cfg.Hash == nil {
+		cfg.Hash = sha256.New
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultSignatureMaxBodySize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			signatures := parseSignatures(r.Header.Get(cfg.Header))
+			if len(signatures) == 0 {
+				rest.Unauthorized(w, r, "missing signature")
+				return
+			}
+
+			timestamp := r.Header.Get(cfg.TimestampHeader)
+			if timestamp == "" {
+				rest.Unauthorized(w, r, "missing signature timestamp")
+				return
+			}
+
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				rest.Unauthorized(w, r, "invalid signature timestamp")
+				return
+			}
+
+			if age := time.Since(time.Unix(unix, 0)); age > cfg.Tolerance || age < -cfg.Tolerance {
+				rest.Unauthorized(w, r, "signature timestamp outside of tolerance")
+				return
+			}
+
+			if r.ContentLength > cfg.MaxBodySize {
+				rest.Error(w, r, &BodyTooLargeError{Limit: cfg.MaxBodySize})
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				body, err = ioutil.ReadAll(io.LimitReader(r.Body, cfg.MaxBodySize+1))
+				_ = r.Body.Close()
+				if err != nil {
+					rest.Error(w, r, err)
+					return
+				}
+				if int64(len(body)) > cfg.MaxBodySize {
+					rest.Error(w, r, &BodyTooLargeError{Limit: cfg.MaxBodySize})
+					return
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+
+			if !cfg.verify(timestamp, body, signatures) {
+				rest.Unauthorized(w, r, "invalid signature")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (cfg *SignatureConfig) verify(timestamp string, body []byte, signatures [][]byte) bool {
+	for _, secret := range cfg.Secrets {
+		mac := hmac.New(cfg.Hash, secret)
+		_, _ = mac.Write([]byte(timestamp))
+		_, _ = mac.Write([]byte("."))
+		_, _ = mac.Write(body)
+		expected := mac.Sum(nil)
+
+		for _, signature := range signatures {
+			if hmac.Equal(expected, signature) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func parseSignatures(header string) [][]byte {
+	var signatures [][]byte
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if i := strings.IndexByte(value, '='); i >= 0 {
+			value = value[i+1:]
+		}
+
+		signature, err := hex.DecodeString(value)
+		if err != nil || len(signature) == 0 {
+			continue
+		}
+		signatures = append(signatures, signature)
+	}
+
+	return signatures
+}
@@ -0,0 +1,124 @@
+package middleware_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+func sign(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	t.Parallel()
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	body := `{"event":"paid"}`
+
+	tests := map[string]struct {
+		signature string
+		timestamp string
+		code      int
+		msg       string
+	}{
+		"valid":             {sign("current", now, body), now, http.StatusOK, ""},
+		"valid prefixed":    {"sha256=" + sign("current", now, body), now, http.StatusOK, ""},
+		"previous secret":   {sign("previous", now, body), now, http.StatusOK, ""},
+		"multiple":          {"v1=abcd, v1=" + sign("current", now, body), now, http.StatusOK, ""},
+		"missing signature": {"", now, http.StatusUnauthorized, "missing signature"},
+		"missing timestamp": {sign("current", now, body), "", http.StatusUnauthorized, "missing signature timestamp"},
+		"invalid timestamp": {sign("current", now, body), "yesterday", http.StatusUnauthorized, "invalid signature timestamp"},
+		"replay":            {sign("current", old, body), old, http.StatusUnauthorized, "signature timestamp outside of tolerance"},
+		"unknown secret":    {sign("unknown", now, body), now, http.StatusUnauthorized, "invalid signature"},
+		"other timestamp":   {sign("current", old, body), now, http.StatusUnauthorized, "invalid signature"},
+	}
+
+	h := middleware.VerifySignature(middleware.SignatureConfig{
+		Secrets: [][]byte{[]byte("current"), []byte("previous")},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != body {
+			t.Errorf(`expected body to be readable, got: '%s'`, b)
+		}
+		rest.Ok(w, r, nil)
+	}))
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+			if tc.signature != "" {
+				req.Header.Set(middleware.DefaultSignatureHeader, tc.signature)
+			}
+			if tc.timestamp != "" {
+				req.Header.Set(middleware.DefaultSignatureTimestampHeader, tc.timestamp)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, tc.code)
+
+			if tc.code != http.StatusOK {
+				resttest.ExpectMessage(t, wrap, tc.msg)
+			}
+		})
+	}
+}
+
+func TestVerifySignature_MaxBodySize(t *testing.T) {
+	t.Parallel()
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	body := `{"event":"paid"}`
+
+	tests := map[string]int64{
+		"content length": int64(len(body)),
+		"unknown length": -1,
+	}
+
+	h := middleware.VerifySignature(middleware.SignatureConfig{
+		Secrets:     [][]byte{[]byte("current")},
+		MaxBodySize: 8,
+	})(okHandler())
+
+	for name, contentLength := range tests {
+		contentLength := contentLength
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+			req.ContentLength = contentLength
+			req.Header.Set(middleware.DefaultSignatureHeader, sign("current", now, body))
+			req.Header.Set(middleware.DefaultSignatureTimestampHeader, now)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, http.StatusRequestEntityTooLarge)
+		})
+	}
+}
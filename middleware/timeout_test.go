@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/middleware"
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	lateWrite := make(chan error, 1)
+	h := middleware.Timeout(10*time.Millisecond, http.StatusGatewayTimeout, "too slow")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(5 * time.Millisecond)
+		_, err := w.Write([]byte("late"))
+		lateWrite <- err
+	}))
+
+	req := httptest.NewRequest("GET", "/unittest", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusAndMessage(t, resp, wrap, http.StatusGatewayTimeout, "too slow")
+
+	if err := <-lateWrite; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Fatalf(`expected late write to fail with '%s', got: '%v'`, http.ErrHandlerTimeout, err)
+	}
+}
+
+func TestTimeout_DefaultMessage(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.Timeout(time.Millisecond, http.StatusServiceUnavailable, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/unittest", nil))
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusAndMessage(t, resp, wrap, http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+}
+
+func TestTimeout_InTime(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.Timeout(time.Second, http.StatusServiceUnavailable, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Unittest", "1")
+		rest.Created(w, r, "data")
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/unittest", nil))
+
+	resp := rr.Result()
+	defer resp.Body.Close()
+	wrap := resttest.ParseToWrapped(t, resp.Body)
+	resttest.ExpectStatusCode(t, resp, wrap, http.StatusCreated)
+
+	if resp.Header.Get("X-Unittest") != "1" {
+		t.Fatal(`expected header of handler to be copied`)
+	}
+}
+
+func TestTimeout_Panic(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.Timeout(time.Second, http.StatusServiceUnavailable, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("unittest")
+	}))
+
+	defer func() {
+		if p := recover(); p != "unittest" {
+			t.Fatalf(`expected panic to be propagated, got: '%v'`, p)
+		}
+	}()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unittest", nil))
+}
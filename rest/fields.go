@@ -0,0 +1,244 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type ctxKey string
+
+const ctxKeySparseFields = ctxKey("sparseFields")
+
+// FieldsParam is the query parameter which contains the sparse fieldset, e.g. "?fields=id,owner.name".
+const FieldsParam = "fields"
+
+// CtxSetSparseFields will set ctxKeySparseFields on ctx.
+//
+// If set to true, Render will prune Data to the fields of FieldsParam, see SelectFields.
+func CtxSetSparseFields(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, ctxKeySparseFields, enabled)
+}
+
+// SparseFieldsFromCtx will get ctxKeySparseFields on ctx.
+func SparseFieldsFromCtx(ctx context.Context) bool {
+	enabled, _ := ctx.Value(ctxKeySparseFields).(bool)
+	return enabled
+}
+
+// FieldSelector can be implemented by types to whitelist the fields which can be selected with
+// SelectFields. Fields of nested types will be selected with their path, e.g. "owner.name".
+// Selecting a field allows all its nested fields.
+type FieldSelector interface {
+	SelectableFields() []string
+}
+
+// FieldsError will be returned by SelectFields if fields are unknown or not selectable.
+//
+// It implements wrapped.StatusCodeResponder and wrapped.DataResponder, so rest.Error will
+// respond with http.StatusBadRequest and the unknown fields as Data.
+type FieldsError struct {
+	Unknown []string `json:"unknown"`
+}
+
+func (e *FieldsError) Error() string {
+	return "unknown fields: " + strings.Join(e.Unknown, ", ")
+}
+
+// StatusCode implements wrapped.StatusCodeResponder.
+func (e *FieldsError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Data implements wrapped.DataResponder.
+func (e *FieldsError) Data() interface{} {
+	return e
+}
+
+// fieldsFromRequest returns the fields of FieldsParam in r.
+func fieldsFromRequest(r *http.Request) []string {
+	var fields []string
+	for _, value := range r.URL.Query()[FieldsParam] {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	return fields
+}
+
+// SelectFields returns data pruned to fields. Nested fields are separated by dots and
+// will be applied to each element of slices, e.g. "id,owner.name".
+//
+// The fields will be validated against the JSON field names of the type of data and its
+// FieldSelector. Unknown fields will be reported with *FieldsError.
+func SelectFields(data interface{}, fields []string) (interface{}, error) {
+	if data == nil || len(fields) == 0 {
+		return data, nil
+	}
+
+	var unknown []string
+	t := reflect.TypeOf(data)
+	for _, field := range fields {
+		if !knownField(t, strings.Split(field, ".")) {
+			unknown = append(unknown, field)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, &FieldsError{Unknown: unknown}
+	}
+
+	raw, err := MarshalFn(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	return pruneFields(generic, newFieldTree(fields)), nil
+}
+
+// fieldTree contains the selected fields, a nil subtree selects all nested fields.
+type fieldTree map[string]fieldTree
+
+func newFieldTree(fields []string) fieldTree {
+	tree := make(fieldTree)
+	for _, field := range fields {
+		node := tree
+		parts := strings.Split(field, ".")
+		for i, part := range parts {
+			sub, ok := node[part]
+			if ok && sub == nil {
+				break
+			}
+			if i == len(parts)-1 {
+				node[part] = nil
+				break
+			}
+			if sub == nil {
+				sub = make(fieldTree)
+				node[part] = sub
+			}
+			node = sub
+		}
+	}
+
+	return tree
+}
+
+func pruneFields(v interface{}, tree fieldTree) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(tree))
+		for name, sub := range tree {
+			value, ok := v[name]
+			if !ok {
+				continue
+			}
+			if sub == nil {
+				out[name] = value
+				continue
+			}
+			out[name] = pruneFields(value, sub)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = pruneFields(item, tree)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// knownField reports if path is a selectable field of t.
+func knownField(t reflect.Type, path []string) bool {
+	for len(path) > 0 {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Interface:
+			return true
+		case reflect.Map:
+			t = t.Elem()
+			path = path[1:]
+		case reflect.Struct:
+			if !fieldSelectable(t, path) {
+				return false
+			}
+			field, ok := structFieldByJSONName(t, path[0])
+			if !ok {
+				return false
+			}
+			t = field.Type
+			path = path[1:]
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// fieldSelectable reports if path is whitelisted by the FieldSelector of t.
+func fieldSelectable(t reflect.Type, path []string) bool {
+	selector, ok := reflect.New(t).Interface().(FieldSelector)
+	if !ok {
+		return true
+	}
+
+	field := strings.Join(path, ".")
+	for _, allowed := range selector.SelectableFields() {
+		if field == allowed || strings.HasPrefix(field, allowed+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// structFieldByJSONName returns the field of t which encoding/json would encode as name.
+func structFieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		jsonName, _, skip := jsonField(field)
+		if skip {
+			continue
+		}
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if f, ok := structFieldByJSONName(embedded, name); ok {
+					return f, true
+				}
+				continue
+			}
+		}
+
+		if field.PkgPath == "" && jsonName == name {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
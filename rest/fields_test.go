@@ -0,0 +1,182 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+type fieldsOwner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type fieldsMeta struct {
+	Version int `json:"version"`
+}
+
+type fieldsProject struct {
+	fieldsMeta
+	ID     int                    `json:"id"`
+	Title  string                 `json:"title,omitempty"`
+	Owner  *fieldsOwner           `json:"owner"`
+	Tags   []string               `json:"tags"`
+	Labels map[string]string      `json:"labels"`
+	Extra  interface{}            `json:"extra"`
+	Secret string                 `json:"-"`
+	Attrs  map[string]fieldsOwner `json:"attrs"`
+}
+
+type fieldsAccount struct {
+	ID      int          `json:"id"`
+	Owner   *fieldsOwner `json:"owner"`
+	Balance int          `json:"balance"`
+}
+
+func (fieldsAccount) SelectableFields() []string {
+	return []string{"id", "owner.name"}
+}
+
+func TestSelectFields(t *testing.T) {
+	t.Parallel()
+
+	project := fieldsProject{
+		fieldsMeta: fieldsMeta{Version: 3},
+		ID:         1,
+		Owner:      &fieldsOwner{Name: "alice", Email: "alice@example.com"},
+		Tags:       []string{"a"},
+		Labels:     map[string]string{"env": "prod", "team": "core"},
+		Extra:      map[string]interface{}{"any": true},
+	}
+	account := fieldsAccount{ID: 1, Owner: &fieldsOwner{Name: "alice", Email: "alice@example.com"}, Balance: 10}
+
+	tests := map[string]struct {
+		data    interface{}
+		fields  []string
+		want    string
+		unknown []string
+	}{
+		"top level": {
+			project, []string{"id", "tags"},
+			`{"id":1,"tags":["a"]}`, nil,
+		},
+		"nested": {
+			&project, []string{"id", "owner.name"},
+			`{"id":1,"owner":{"name":"alice"}}`, nil,
+		},
+		"nested and parent": {
+			project, []string{"owner.name", "owner"},
+			`{"owner":{"email":"alice@example.com","name":"alice"}}`, nil,
+		},
+		"embedded": {
+			project, []string{"version"},
+			`{"version":3}`, nil,
+		},
+		"omitted": {
+			project, []string{"title"},
+			`{}`, nil,
+		},
+		"map": {
+			project, []string{"labels.env", "extra.any", "attrs.x.name"},
+			`{"attrs":null,"extra":{"any":true},"labels":{"env":"prod"}}`, nil,
+		},
+		"slice": {
+			[]fieldsProject{project, {ID: 2}}, []string{"id", "owner.name"},
+			`[{"id":1,"owner":{"name":"alice"}},{"id":2,"owner":null}]`, nil,
+		},
+		"unknown": {
+			project, []string{"id", "secret", "owner.phone", "tags.x", "attrs.x.phone"},
+			"", []string{"attrs.x.phone", "owner.phone", "secret", "tags.x"},
+		},
+		"whitelisted": {
+			account, []string{"id", "owner.name"},
+			`{"id":1,"owner":{"name":"alice"}}`, nil,
+		},
+		"not whitelisted": {
+			[]fieldsAccount{account}, []string{"balance", "owner", "owner.email"},
+			"", []string{"balance", "owner", "owner.email"},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := rest.SelectFields(tc.data, tc.fields)
+			if tc.unknown != nil {
+				var fieldsErr *rest.FieldsError
+				if !errors.As(err, &fieldsErr) {
+					t.Fatalf(`expected FieldsError, got: '%v'`, err)
+				}
+				if !reflect.DeepEqual(fieldsErr.Unknown, tc.unknown) {
+					t.Fatalf(`expected unknown fields '%v', got: '%v'`, tc.unknown, fieldsErr.Unknown)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+
+			got, _ := json.Marshal(data)
+			if string(got) != tc.want {
+				t.Fatalf(`expected '%s', got: '%s'`, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRender_SparseFields(t *testing.T) {
+	t.Parallel()
+
+	project := fieldsProject{ID: 1, Title: "go-rest", Owner: &fieldsOwner{Name: "alice"}}
+
+	tests := map[string]struct {
+		target  string
+		enabled bool
+		code    int
+		data    string
+	}{
+		"disabled":      {"/projects/1?fields=id", false, http.StatusOK, `{"version":0,"id":1,"title":"go-rest","owner":{"name":"alice","email":""},"tags":null,"labels":null,"extra":null,"attrs":null}`},
+		"without param": {"/projects/1", true, http.StatusOK, `{"version":0,"id":1,"title":"go-rest","owner":{"name":"alice","email":""},"tags":null,"labels":null,"extra":null,"attrs":null}`},
+		"selected":      {"/projects/1?fields=id,owner.name", true, http.StatusOK, `{"id":1,"owner":{"name":"alice"}}`},
+		"repeated":      {"/projects/1?fields=id&fields=title", true, http.StatusOK, `{"id":1,"title":"go-rest"}`},
+		"unknown":       {"/projects/1?fields=id,name", true, http.StatusBadRequest, `{"unknown":["name"]}`},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", tc.target, nil)
+			req = req.WithContext(rest.CtxSetSparseFields(context.Background(), tc.enabled))
+			rr := httptest.NewRecorder()
+			rest.Ok(rr, req, project)
+
+			var res struct {
+				wrapped.Response
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("could not parse body, err: '%s'", err)
+			}
+
+			if res.Code != tc.code {
+				t.Fatalf(`expected Code to be '%d', got: '%d'`, tc.code, res.Code)
+			}
+			if string(res.Data) != tc.data {
+				t.Fatalf(`expected Data to be '%s', got: '%s'`, tc.data, res.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package rest
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// MediaTypeHAL is the media type of the Hypertext Application Language, see
+// https://datatracker.ietf.org/doc/html/draft-kelly-json-hal.
+const MediaTypeHAL = "application/hal+json"
+
+// HALLinker can be implemented by Data to add links to the _links of its HAL resource.
+type HALLinker interface {
+	HALLinks() []wrapped.Link
+}
+
+// HALEmbedder can be implemented by Data to add resources to the _embedded of its HAL resource.
+type HALEmbedder interface {
+	HALEmbedded() map[string]interface{}
+}
+
+// halLink is a link object of _links.
+type halLink struct {
+	Href   string `json:"href"`
+	Title  string `json:"title,omitempty"`
+	Method string `json:"method,omitempty"`
+}
+
+// MarshalHAL is the EncoderFn of MediaTypeHAL.
+//
+// The properties of a struct in Data become the properties of the HAL document, next to the code,
+// status and message of res. Fields can be moved with struct tags:
+//
+//  type Order struct {
+//      ID       string `json:"id"`
+//      Customer string `json:"customer" hal:"link"` // _links.customer.href
+//      Items    []Item `json:"items" hal:"embedded"` // _embedded.items
+//  }
+//
+// Data which implements json.Marshaler or encoding.TextMarshaler, e.g. time.Time, will be encoded
+// with it. If its JSON is an object, the properties become the properties of the HAL document.
+//
+// Slices in Data will be embedded as "items". Other Data will be kept in "data". The Links of res
+// and HALLinker become _links, HALEmbedder becomes _embedded. Hrefs will be resolved with ResolveURL.
+// GET requests get a "self" link to the requested resource if res contains none.
+func MarshalHAL(r *http.Request, res *wrapped.Response) ([]byte, error) {
+	doc := make(map[string]interface{})
+	links := make(map[string][]halLink)
+	embedded := make(map[string]interface{})
+
+	if res.Data != nil {
+		v := reflect.ValueOf(res.Data)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+
+		switch {
+		case isMarshaler(res.Data) || v.Kind() == reflect.Struct:
+			ok, err := halCollect(r, res.Data, doc, links, embedded)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				doc["data"] = res.Data
+			}
+		case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && res.Status == wrapped.StatusSuccess:
+			items, err := halValue(r, v)
+			if err != nil {
+				return nil, err
+			}
+			embedded["items"] = items
+		default:
+			doc["data"] = res.Data
+		}
+	}
+
+	for _, link := range res.Links {
+		links[link.Rel] = append(links[link.Rel], halLink{Href: ResolveURL(r, link.Href), Title: link.Title, Method: link.Method})
+	}
+	if _, ok := links["self"]; !ok && r.Method == http.MethodGet && res.Status == wrapped.StatusSuccess {
+		links["self"] = []halLink{{Href: ResolveURL(r, "")}}
+	}
+
+	mergeHALLinks(doc, links)
+	if len(embedded) > 0 {
+		doc["_embedded"] = embedded
+	}
+
+	doc["code"] = res.Code
+	doc["status"] = res.Status
+	if res.Message != "" {
+		doc["message"] = res.Message
+	}
+
+	return MarshalFn(doc)
+}
+
+// halResource returns the HAL resource of data or data unchanged if it is no JSON object.
+func halResource(r *http.Request, data interface{}) (interface{}, error) {
+	doc := make(map[string]interface{})
+	links := make(map[string][]halLink)
+	embedded := make(map[string]interface{})
+
+	ok, err := halCollect(r, data, doc, links, embedded)
+	if err != nil || !ok {
+		return data, err
+	}
+
+	mergeHALLinks(doc, links)
+	if len(embedded) > 0 {
+		doc["_embedded"] = embedded
+	}
+
+	return doc, nil
+}
+
+// halCollect adds the properties, links and embedded resources of data. It reports false if
+// data is neither a struct nor encoded to a JSON object by its marshaler.
+func halCollect(r *http.Request, data interface{}, doc map[string]interface{}, links map[string][]halLink, embedded map[string]interface{}) (bool, error) {
+	if isMarshaler(data) {
+		obj, err := marshalObject(data)
+		if err != nil || obj == nil {
+			return false, err
+		}
+		for name, value := range obj {
+			doc[name] = value
+		}
+	} else {
+		v := reflect.ValueOf(data)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return false, nil
+		}
+		if err := halFields(r, v, doc, links, embedded); err != nil {
+			return false, err
+		}
+	}
+
+	if linker, ok := data.(HALLinker); ok {
+		for _, link := range linker.HALLinks() {
+			links[link.Rel] = append(links[link.Rel], halLink{Href: ResolveURL(r, link.Href), Title: link.Title, Method: link.Method})
+		}
+	}
+	if embedder, ok := data.(HALEmbedder); ok {
+		for name, value := range embedder.HALEmbedded() {
+			resource, err := halValue(r, reflect.ValueOf(value))
+			if err != nil {
+				return false, err
+			}
+			embedded[name] = resource
+		}
+	}
+
+	return true, nil
+}
+
+func halFields(r *http.Request, v reflect.Value, doc map[string]interface{}, links map[string][]halLink, embedded map[string]interface{}) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		name, omitEmpty, skip := jsonField(field)
+		if skip {
+			continue
+		}
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			for value.Kind() == reflect.Ptr && !value.IsNil() {
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				if err := halFields(r, value, doc, links, embedded); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !value.CanInterface() || (omitEmpty && isEmptyValue(value)) {
+			continue
+		}
+
+		switch field.Tag.Get("hal") {
+		case "link":
+			if href, ok := value.Interface().(string); ok && href != "" {
+				links[name] = append(links[name], halLink{Href: ResolveURL(r, href)})
+			}
+		case "embedded":
+			if !isEmptyValue(value) {
+				resource, err := halValue(r, value)
+				if err != nil {
+					return err
+				}
+				embedded[name] = resource
+			}
+		default:
+			doc[name] = value.Interface()
+		}
+	}
+
+	return nil
+}
+
+// halValue returns the HAL resource of structs and marshalers, of each of them in slices and
+// other values unchanged.
+func halValue(r *http.Request, v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.CanInterface() && isMarshaler(v.Interface()) {
+		return halResource(r, v.Interface())
+	}
+
+	elem := v
+	for elem.Kind() == reflect.Ptr && !elem.IsNil() {
+		elem = elem.Elem()
+	}
+
+	switch elem.Kind() {
+	case reflect.Struct:
+		return halResource(r, v.Interface())
+	case reflect.Slice, reflect.Array:
+		if elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface(), nil
+		}
+		items := make([]interface{}, elem.Len())
+		for i := range items {
+			item, err := halValue(r, elem.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// isMarshaler reports if data encodes itself to JSON, so its fields must not be reflected.
+func isMarshaler(data interface{}) bool {
+	switch data.(type) {
+	case json.Marshaler, encoding.TextMarshaler:
+		return true
+	default:
+		return false
+	}
+}
+
+// marshalObject returns the JSON object of data or nil if data is encoded to another JSON value.
+func marshalObject(data interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	obj, _ := value.(map[string]interface{})
+	return obj, nil
+}
+
+// mergeHALLinks sets links as _links on doc. A single link of a relation will be rendered as object.
+func mergeHALLinks(doc map[string]interface{}, links map[string][]halLink) {
+	if len(links) == 0 {
+		return
+	}
+
+	out := make(map[string]interface{}, len(links))
+	for rel, l := range links {
+		if len(l) == 1 {
+			out[rel] = l[0]
+			continue
+		}
+		out[rel] = l
+	}
+	doc["_links"] = out
+}
+
+// jsonField returns the name and the omitempty option of field as encoding/json would use it.
+func jsonField(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return name, omitEmpty, false
+}
+
+// isEmptyValue reports if v is empty as defined by the omitempty option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,235 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+type halItem struct {
+	SKU string `json:"sku"`
+}
+
+type halOrder struct {
+	ID       string    `json:"id"`
+	Note     string    `json:"note,omitempty"`
+	Customer string    `json:"customer" hal:"link"`
+	Items    []halItem `json:"items" hal:"embedded"`
+	internal string
+}
+
+func (o halOrder) HALLinks() []wrapped.Link {
+	return []wrapped.Link{{Rel: "cancel", Href: "/api/orders/" + o.ID, Method: http.MethodDelete}}
+}
+
+type halAccount struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+func (a halAccount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"name": a.Name})
+}
+
+func (a halAccount) HALLinks() []wrapped.Link {
+	return []wrapped.Link{{Rel: "account", Href: "/api/accounts/" + a.Name}}
+}
+
+func renderHAL(t *testing.T, method string, res *wrapped.Response) map[string]interface{} {
+	req := httptest.NewRequest(method, "/api/orders/1", nil)
+	req.Host = "example.com"
+	req.Header.Set("Accept", rest.MediaTypeHAL)
+	rr := httptest.NewRecorder()
+	rest.Render(rr, req, res)
+
+	if ct := rr.Header().Get("Content-Type"); ct != rest.MediaTypeHAL+"; charset=utf-8" {
+		t.Fatalf(`expected Content-Type to be HAL, got: '%s'`, ct)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not parse HAL document, err: '%s'", err)
+	}
+
+	return doc
+}
+
+func TestMarshalHAL_Resource(t *testing.T) {
+	t.Parallel()
+
+	order := halOrder{ID: "1", Customer: "/api/customers/7", Items: []halItem{{SKU: "a"}, {SKU: "b"}}, internal: "x"}
+	doc := renderHAL(t, "GET", &wrapped.Response{Data: order})
+
+	want := map[string]interface{}{
+		"code":   float64(http.StatusOK),
+		"status": wrapped.StatusSuccess,
+		"id":     "1",
+		"_links": map[string]interface{}{
+			"self":     map[string]interface{}{"href": "http://example.com/api/orders/1"},
+			"customer": map[string]interface{}{"href": "http://example.com/api/customers/7"},
+			"cancel":   map[string]interface{}{"href": "http://example.com/api/orders/1", "method": "DELETE"},
+		},
+		"_embedded": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"sku": "a"},
+				map[string]interface{}{"sku": "b"},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("expected HAL document '%v', got: '%v'", want, doc)
+	}
+}
+
+func TestMarshalHAL(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method string
+		res    *wrapped.Response
+		want   map[string]interface{}
+	}{
+		"collection": {
+			"GET",
+			&wrapped.Response{Data: []halItem{{SKU: "a"}}, Links: []wrapped.Link{{Rel: "next", Href: "?page=2"}}},
+			map[string]interface{}{
+				"code":   float64(http.StatusOK),
+				"status": wrapped.StatusSuccess,
+				"_links": map[string]interface{}{
+					"self": map[string]interface{}{"href": "http://example.com/api/orders/1"},
+					"next": map[string]interface{}{"href": "http://example.com/api/orders/1?page=2"},
+				},
+				"_embedded": map[string]interface{}{"items": []interface{}{map[string]interface{}{"sku": "a"}}},
+			},
+		},
+		"scalar": {
+			"POST",
+			&wrapped.Response{Code: http.StatusAccepted, Data: "queued"},
+			map[string]interface{}{
+				"code":   float64(http.StatusAccepted),
+				"status": wrapped.StatusSuccess,
+				"data":   "queued",
+			},
+		},
+		"error": {
+			"GET",
+			&wrapped.Response{Code: http.StatusNotFound, Message: "order not found"},
+			map[string]interface{}{
+				"code":    float64(http.StatusNotFound),
+				"status":  wrapped.StatusError,
+				"message": "order not found",
+			},
+		},
+		"marshaler": {
+			"POST",
+			&wrapped.Response{Data: halAccount{Name: "alice", Password: "secret"}},
+			map[string]interface{}{
+				"code":   float64(http.StatusOK),
+				"status": wrapped.StatusSuccess,
+				"name":   "alice",
+				"_links": map[string]interface{}{
+					"account": map[string]interface{}{"href": "http://example.com/api/accounts/alice"},
+				},
+			},
+		},
+		"embedded marshaler": {
+			"POST",
+			&wrapped.Response{Data: []halAccount{{Name: "alice", Password: "secret"}}},
+			map[string]interface{}{
+				"code":   float64(http.StatusOK),
+				"status": wrapped.StatusSuccess,
+				"_embedded": map[string]interface{}{"items": []interface{}{
+					map[string]interface{}{
+						"name":   "alice",
+						"_links": map[string]interface{}{"account": map[string]interface{}{"href": "http://example.com/api/accounts/alice"}},
+					},
+				}},
+			},
+		},
+		"time": {
+			"POST",
+			&wrapped.Response{Data: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)},
+			map[string]interface{}{
+				"code":   float64(http.StatusOK),
+				"status": wrapped.StatusSuccess,
+				"data":   "2021-03-04T05:06:07Z",
+			},
+		},
+		"multiple links": {
+			"POST",
+			&wrapped.Response{Links: []wrapped.Link{{Rel: "item", Href: "/a"}, {Rel: "item", Href: "/b", Title: "B"}}},
+			map[string]interface{}{
+				"code":   float64(http.StatusOK),
+				"status": wrapped.StatusSuccess,
+				"_links": map[string]interface{}{
+					"item": []interface{}{
+						map[string]interface{}{"href": "http://example.com/a"},
+						map[string]interface{}{"href": "http://example.com/b", "title": "B"},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			doc := renderHAL(t, tc.method, tc.res)
+			if !reflect.DeepEqual(doc, tc.want) {
+				t.Fatalf("expected HAL document '%v', got: '%v'", tc.want, doc)
+			}
+		})
+	}
+}
+
+func TestRender_Negotiate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		accept      string
+		contentType string
+	}{
+		"no accept":        {"", rest.MediaTypeJSON},
+		"json":             {"application/json", rest.MediaTypeJSON},
+		"any":              {"*/*", rest.MediaTypeJSON},
+		"hal":              {"application/hal+json", rest.MediaTypeHAL},
+		"hal preferred":    {"application/json;q=0.5, application/hal+json", rest.MediaTypeHAL},
+		"json preferred":   {"application/hal+json;q=0.5, application/json", rest.MediaTypeJSON},
+		"specific wins":    {"application/*;q=0.1, application/hal+json;q=0.9", rest.MediaTypeHAL},
+		"unsupported":      {"text/html", rest.MediaTypeJSON},
+		"invalid":          {"application/hal+json;q", rest.MediaTypeHAL},
+		"hal not accepted": {"application/hal+json;q=0, */*", rest.MediaTypeJSON},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/unittest", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+			rest.Ok(rr, req, "data")
+
+			if ct := rr.Header().Get("Content-Type"); ct != tc.contentType+"; charset=utf-8" {
+				t.Fatalf(`expected Content-Type to be '%s', got: '%s'`, tc.contentType, ct)
+			}
+			if vary := rr.Header().Get("Vary"); vary != "Accept" {
+				t.Fatalf(`expected Vary to be 'Accept', got: '%s'`, vary)
+			}
+		})
+	}
+}
@@ -68,14 +68,35 @@ func Gone(w http.ResponseWriter, r *http.Request, msg string) {
 	responseWithMessage(w, r, http.StatusGone, msg)
 }
 
+// LengthRequired Server rejected the request because the Content-Length header field
+// is not defined and the server requires it.
+func LengthRequired(w http.ResponseWriter, r *http.Request, msg string) {
+	responseWithMessage(w, r, http.StatusLengthRequired, msg)
+}
+
+// PayloadTooLarge Request entity is larger than limits defined by server; the server
+// might close the connection or return an Retry-After header field.
+func PayloadTooLarge(w http.ResponseWriter, r *http.Request, msg string) {
+	responseWithMessage(w, r, http.StatusRequestEntityTooLarge, msg)
+}
+
 // UnsupportedMediaType The media format of the requested data is not supported by
 // the server, so the server is rejecting the request.
 func UnsupportedMediaType(w http.ResponseWriter, r *http.Request, msg string) {
 	responseWithMessage(w, r, http.StatusUnsupportedMediaType, msg)
 }
 
+// UnprocessableEntity The request was well-formed but was unable to be followed due
+// to semantic errors.
+func UnprocessableEntity(w http.ResponseWriter, r *http.Request, msg string) {
+	responseWithMessage(w, r, http.StatusUnprocessableEntity, msg)
+}
+
 // TooManyRequests The user has sent too many requests in a given amount of time
 // ("rate limiting").
+//
+// Use TooManyRequestsRetryAfter, TooManyRequestsRetryAt or TooManyRequestsRateLimit to
+// send a Retry-After header.
 func TooManyRequests(w http.ResponseWriter, r *http.Request, msg string) {
 	responseWithMessage(w, r, http.StatusTooManyRequests, msg)
 }
@@ -109,6 +130,8 @@ func NotImplemented(w http.ResponseWriter, r *http.Request, msg string) {
 // recovery of the service. The webmaster must also take care about the caching-related
 // headers that are sent along with this response, as these temporary condition
 // responses should usually not be cached.
+//
+// Use ServiceUnavailableRetryAfter or ServiceUnavailableRetryAt to send a Retry-After header.
 func ServiceUnavailable(w http.ResponseWriter, r *http.Request, msg string) {
 	responseWithMessage(w, r, http.StatusServiceUnavailable, msg)
 }
@@ -131,16 +154,22 @@ func Ok(w http.ResponseWriter, r *http.Request, data interface{}) {
 
 // Created The request has succeeded and a new resource has been created as a result.
 // This is typically the response sent after POST requests, or some PUT requests.
+//
+// The Location header will be set to the new resource if data implements Locator or the
+// header is already set. It will be resolved to an absolute URL and mirrored as "self" link.
 func Created(w http.ResponseWriter, r *http.Request, data interface{}) {
-	responseWithData(w, r, http.StatusCreated, data)
+	responseWithLocation(w, r, http.StatusCreated, "", data, nil)
 }
 
 // Accepted The request has been received but not yet acted upon. It is noncommittal,
 // since there is no way in HTTP to later send an asynchronous response indicating
 // the outcome of the request. It is intended for cases where another process or server
 // handles the request, or for batch processing.
+//
+// The Location header will be set to the status resource if data implements Locator or the
+// header is already set. It will be resolved to an absolute URL and mirrored as "self" link.
 func Accepted(w http.ResponseWriter, r *http.Request, data interface{}) {
-	responseWithData(w, r, http.StatusAccepted, data)
+	responseWithLocation(w, r, http.StatusAccepted, "", data, nil)
 }
 
 // NoContent There is no content to send for this request, but the headers may be useful.
@@ -56,12 +56,30 @@ func(w http.ResponseWriter, r *http.Request) {
 				rest.Gone(w, r, "msg")
 			},
 		},
+		"length required": {
+			http.StatusLengthRequired, wrapped.StatusError, nil, "msg",
+			func(w http.ResponseWriter, r *http.Request) {
+				rest.LengthRequired(w, r, "msg")
+			},
+		},
+		"payload too large": {
+			http.StatusRequestEntityTooLarge, wrapped.StatusError, nil, "msg",
+			func(w http.ResponseWriter, r *http.Request) {
+				rest.PayloadTooLarge(w, r, "msg")
+			},
+		},
 		"unsupported MediaType": {
 			http.StatusUnsupportedMediaType, wrapped.StatusError, nil, "msg",
 			func(w http.ResponseWriter, r *http.Request) {
 				rest.UnsupportedMediaType(w, r, "msg")
 			},
 		},
+		"unprocessable entity": {
+			http.StatusUnprocessableEntity, wrapped.StatusError, nil, "msg",
+			func(w http.ResponseWriter, r *http.Request) {
+				rest.UnprocessableEntity(w, r, "msg")
+			},
+		},
 		"too many requests": {
 			http.StatusTooManyRequests, wrapped.StatusError, nil, "msg",
 			func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,456 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// MediaTypeJSONAPI is the media type of JSON:API, see https://jsonapi.org/format/.
+const MediaTypeJSONAPI = "application/vnd.api+json"
+
+// JSONAPIError is an error object of a JSON:API document.
+type JSONAPIError struct {
+	ID     string                 `json:"id,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	Code   string                 `json:"code,omitempty"`
+	Title  string                 `json:"title,omitempty"`
+	Detail string                 `json:"detail,omitempty"`
+	Source *JSONAPIErrorSource    `json:"source,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+// JSONAPIErrorSource references the source of a JSONAPIError.
+type JSONAPIErrorSource struct {
+	// Pointer is a JSON Pointer to the value in the request document, e.g. "/data/attributes/title".
+	Pointer string `json:"pointer,omitempty"`
+	// Parameter is the name of the query parameter which caused the error.
+	Parameter string `json:"parameter,omitempty"`
+}
+
+// JSONAPIErrorer can be implemented by errors to render multiple error objects, e.g. for
+// validation errors.
+type JSONAPIErrorer interface {
+	JSONAPIErrors() []JSONAPIError
+}
+
+// JSONAPIIncludeError will be returned by MarshalJSONAPI if a path of the "include" query
+// parameter is no relationship of the primary data.
+//
+// It implements wrapped.StatusCodeResponder and JSONAPIErrorer, so rest.Error will respond
+// with http.StatusBadRequest.
+type JSONAPIIncludeError struct {
+	Path string
+}
+
+func (e *JSONAPIIncludeError) Error() string {
+	return fmt.Sprintf("cannot include %q, it is no relationship", e.Path)
+}
+
+// StatusCode implements wrapped.StatusCodeResponder.
+func (e *JSONAPIIncludeError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// JSONAPIErrors implements JSONAPIErrorer.
+func (e *JSONAPIIncludeError) JSONAPIErrors() []JSONAPIError {
+	return []JSONAPIError{{
+		Title:  http.StatusText(http.StatusBadRequest),
+		Detail: e.Error(),
+		Source: &JSONAPIErrorSource{Parameter: "include"},
+	}}
+}
+
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type jsonAPIRelationship struct {
+	// Data is nil, *jsonAPIIdentifier or []jsonAPIIdentifier.
+	Data interface{} `json:"data"`
+}
+
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]interface{}         `json:"attributes,omitempty"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+}
+
+// MarshalJSONAPI is the EncoderFn of MediaTypeJSONAPI.
+//
+// Data will be converted into resource objects with struct tags:
+//
+//  type Article struct {
+//      ID       int       `jsonapi:"primary,articles"`
+//      Title    string    `jsonapi:"attr,title"`
+//      Subtitle string    `jsonapi:"attr,subtitle,omitempty"`
+//      Author   *Person   `jsonapi:"relation,author"`
+//      Comments []Comment `jsonapi:"relation,comments"`
+//  }
+//
+// Related resources will be added to included, limited to the paths of the "include" query
+// parameter if present. Include paths which are no relationship will be rejected with a
+// *JSONAPIIncludeError. The "fields[type]" query parameters select sparse fieldsets. Data
+// without primary tag will be rendered as meta.
+//
+// Error responses render an errors array. It contains the errors of JSONAPIErrorer, the
+// []JSONAPIError in Data or one error object built from the code, message and data of res.
+func MarshalJSONAPI(r *http.Request, res *wrapped.Response) ([]byte, error) {
+	doc := map[string]interface{}{
+		"jsonapi": map[string]string{"version": "1.0"},
+	}
+
+	if len(res.Links) > 0 {
+		links := make(map[string]string, len(res.Links))
+		for _, link := range res.Links {
+			links[link.Rel] = ResolveURL(r, link.Href)
+		}
+		doc["links"] = links
+	}
+
+	if res.Status != wrapped.StatusSuccess {
+		doc["errors"] = jsonAPIErrors(res)
+		return MarshalFn(doc)
+	}
+
+	e := newJSONAPIEncoder(r)
+	if err := e.checkInclude(res.Data); err != nil {
+		return nil, err
+	}
+
+	data, ok := e.data(res.Data)
+	if !ok {
+		doc["meta"] = map[string]interface{}{"data": res.Data}
+		return MarshalFn(doc)
+	}
+
+	doc["data"] = data
+	if len(e.included) > 0 {
+		doc["included"] = e.included
+	}
+
+	return MarshalFn(doc)
+}
+
+func jsonAPIErrors(res *wrapped.Response) []JSONAPIError {
+	var errs []JSONAPIError
+
+	var errorer JSONAPIErrorer
+	if errors.As(res.Err, &errorer) {
+		errs = errorer.JSONAPIErrors()
+	} else if data, ok := res.Data.([]JSONAPIError); ok {
+		errs = data
+	}
+
+	status := strconv.Itoa(res.Code)
+	if len(errs) == 0 {
+		e := JSONAPIError{Status: status, Title: http.StatusText(res.Code)}
+		if res.Message != e.Title {
+			e.Detail = res.Message
+		}
+		if res.Data != nil {
+			e.Meta = map[string]interface{}{"data": res.Data}
+		}
+		return []JSONAPIError{e}
+	}
+
+	out := make([]JSONAPIError, len(errs))
+	for i, e := range errs {
+		if e.Status == "" {
+			e.Status = status
+		}
+		out[i] = e
+	}
+
+	return out
+}
+
+type jsonAPIEncoder struct {
+	// fields contains the sparse fieldsets by type.
+	fields map[string]map[string]bool
+	// include contains the relationship paths to include, nil includes all.
+	include map[string]bool
+	// includePaths contains the requested paths of the "include" query parameter.
+	includePaths []string
+	included     []jsonAPIResource
+	seen         map[jsonAPIIdentifier]bool
+}
+
+func newJSONAPIEncoder(r *http.Request) *jsonAPIEncoder {
+	e := &jsonAPIEncoder{seen: make(map[jsonAPIIdentifier]bool)}
+
+	query := r.URL.Query()
+	for key, values := range query {
+		if !strings.HasPrefix(key, "fields[") || !strings.HasSuffix(key, "]") || len(values) == 0 {
+			continue
+		}
+		if e.fields == nil {
+			e.fields = make(map[string]map[string]bool)
+		}
+
+		fields := make(map[string]bool)
+		for _, field := range strings.Split(values[0], ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields[field] = true
+			}
+		}
+		e.fields[key[len("fields["):len(key)-1]] = fields
+	}
+
+	if _, ok := query["include"]; ok {
+		e.include = make(map[string]bool)
+		for _, path := range strings.Split(query.Get("include"), ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				e.includePaths = append(e.includePaths, path)
+			}
+			parts := strings.Split(path, ".")
+			for i := range parts {
+				e.include[strings.Join(parts[:i+1], ".")] = true
+			}
+		}
+	}
+
+	return e
+}
+
+// checkInclude reports the first include path which is no relationship of the resources in v.
+func (e *jsonAPIEncoder) checkInclude(v interface{}) error {
+	if len(e.includePaths) == 0 || v == nil {
+		return nil
+	}
+
+	t := relatedType(reflect.TypeOf(v))
+	if !isJSONAPIResource(t) {
+		return nil
+	}
+
+	for _, path := range e.includePaths {
+		related := t
+		for _, name := range strings.Split(path, ".") {
+			var ok bool
+			if related, ok = relationType(related, name); !ok {
+				return &JSONAPIIncludeError{Path: path}
+			}
+		}
+	}
+
+	return nil
+}
+
+// relationType returns the resource type of the relationship name of the resource type t.
+func relationType(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if kind, relation, _ := jsonAPITag(field); kind == "relation" && relation == name {
+			related := relatedType(field.Type)
+			return related, isJSONAPIResource(related)
+		}
+	}
+
+	return nil, false
+}
+
+// relatedType returns the struct type of t, its slices and pointers.
+func relatedType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+// data returns the primary data of v, it returns false if v is no resource.
+func (e *jsonAPIEncoder) data(v interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, true
+	}
+
+	rv := indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Ptr:
+		return nil, isJSONAPIResource(rv.Type())
+	case reflect.Slice, reflect.Array:
+		if !isJSONAPIResource(rv.Type().Elem()) {
+			return nil, false
+		}
+
+		for i := 0; i < rv.Len(); i++ {
+			if id, ok := jsonAPIIdentify(rv.Index(i)); ok {
+				e.seen[id] = true
+			}
+		}
+
+		resources := make([]jsonAPIResource, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if resource, ok := e.resource(rv.Index(i), ""); ok {
+				resources = append(resources, resource)
+			}
+		}
+		return resources, true
+	case reflect.Struct:
+		id, ok := jsonAPIIdentify(rv)
+		if !ok {
+			return nil, false
+		}
+
+		e.seen[id] = true
+		resource, _ := e.resource(rv, "")
+		return resource, true
+	default:
+		return nil, false
+	}
+}
+
+// resource converts v into a resource object and adds the related resources to included.
+func (e *jsonAPIEncoder) resource(v reflect.Value, path string) (jsonAPIResource, bool) {
+	v = indirect(v)
+	id, ok := jsonAPIIdentify(v)
+	if !ok {
+		return jsonAPIResource{}, false
+	}
+
+	resource := jsonAPIResource{Type: id.Type, ID: id.ID}
+	fields := e.fields[id.Type]
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		kind, name, omitEmpty := jsonAPITag(t.Field(i))
+		if kind == "" || kind == "primary" || (fields != nil && !fields[name]) {
+			continue
+		}
+
+		value := v.Field(i)
+		if !value.CanInterface() {
+			continue
+		}
+
+		switch kind {
+		case "attr":
+			if omitEmpty && isEmptyValue(value) {
+				continue
+			}
+			if resource.Attributes == nil {
+				resource.Attributes = make(map[string]interface{})
+			}
+			resource.Attributes[name] = value.Interface()
+		case "relation":
+			if resource.Relationships == nil {
+				resource.Relationships = make(map[string]jsonAPIRelationship)
+			}
+			resource.Relationships[name] = e.relationship(value, path+name)
+		}
+	}
+
+	return resource, true
+}
+
+// relationship returns the resource linkage of v and includes the related resources if requested.
+func (e *jsonAPIEncoder) relationship(v reflect.Value, path string) jsonAPIRelationship {
+	include := e.include == nil || e.include[path]
+
+	related := indirect(v)
+	if related.Kind() != reflect.Slice && related.Kind() != reflect.Array {
+		id, ok := jsonAPIIdentify(related)
+		if !ok {
+			return jsonAPIRelationship{}
+		}
+		if include {
+			e.add(related, id, path)
+		}
+		return jsonAPIRelationship{Data: &id}
+	}
+
+	ids := make([]jsonAPIIdentifier, 0, related.Len())
+	for i := 0; i < related.Len(); i++ {
+		item := indirect(related.Index(i))
+		id, ok := jsonAPIIdentify(item)
+		if !ok {
+			continue
+		}
+		ids = append(ids, id)
+		if include {
+			e.add(item, id, path)
+		}
+	}
+
+	return jsonAPIRelationship{Data: ids}
+}
+
+// add adds v to included unless it is already part of the document.
+func (e *jsonAPIEncoder) add(v reflect.Value, id jsonAPIIdentifier, path string) {
+	if e.seen[id] {
+		return
+	}
+	e.seen[id] = true
+
+	// reserve the position, so resources are included before their related resources
+	i := len(e.included)
+	e.included = append(e.included, jsonAPIResource{})
+	e.included[i], _ = e.resource(v, path+".")
+}
+
+// jsonAPITag returns the kind ("primary", "attr" or "relation"), the name and the omitempty option of field.
+func jsonAPITag(field reflect.StructField) (kind, name string, omitEmpty bool) {
+	parts := strings.Split(field.Tag.Get("jsonapi"), ",")
+	if len(parts) < 2 {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], len(parts) > 2 && parts[2] == "omitempty"
+}
+
+// jsonAPIIdentify returns the type and id of the struct v.
+func jsonAPIIdentify(v reflect.Value) (jsonAPIIdentifier, bool) {
+	v = indirect(v)
+	if v.Kind() != reflect.Struct {
+		return jsonAPIIdentifier{}, false
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		kind, typ, _ := jsonAPITag(t.Field(i))
+		if kind != "primary" {
+			continue
+		}
+
+		id := v.Field(i)
+		if !id.CanInterface() {
+			return jsonAPIIdentifier{}, false
+		}
+		return jsonAPIIdentifier{Type: typ, ID: fmt.Sprint(id.Interface())}, true
+	}
+
+	return jsonAPIIdentifier{}, false
+}
+
+func isJSONAPIResource(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if kind, _, _ := jsonAPITag(t.Field(i)); kind == "primary" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	return v
+}
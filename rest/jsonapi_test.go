@@ -0,0 +1,312 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+type apiPerson struct {
+	ID   string `jsonapi:"primary,people"`
+	Name string `jsonapi:"attr,name"`
+}
+
+type apiComment struct {
+	ID     int        `jsonapi:"primary,comments"`
+	Body   string     `jsonapi:"attr,body"`
+	Author *apiPerson `jsonapi:"relation,author"`
+}
+
+type apiArticle struct {
+	ID       int          `jsonapi:"primary,articles"`
+	Title    string       `jsonapi:"attr,title"`
+	Subtitle string       `jsonapi:"attr,subtitle,omitempty"`
+	Author   *apiPerson   `jsonapi:"relation,author"`
+	Editor   *apiPerson   `jsonapi:"relation,editor"`
+	Comments []apiComment `jsonapi:"relation,comments"`
+	Internal string
+}
+
+type validationErrors []rest.JSONAPIError
+
+func (e validationErrors) Error() string {
+	return "validation failed"
+}
+
+func (e validationErrors) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+func (e validationErrors) JSONAPIErrors() []rest.JSONAPIError {
+	return e
+}
+
+func renderJSONAPI(t *testing.T, target string, res *wrapped.Response) map[string]interface{} {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Host = "example.com"
+	req.Header.Set("Accept", rest.MediaTypeJSONAPI)
+	rr := httptest.NewRecorder()
+	rest.Render(rr, req, res)
+
+	if ct := rr.Header().Get("Content-Type"); ct != rest.MediaTypeJSONAPI {
+		t.Fatalf(`expected Content-Type to be '%s', got: '%s'`, rest.MediaTypeJSONAPI, ct)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("could not parse JSON:API document, err: '%s'", err)
+	}
+	delete(doc, "jsonapi")
+
+	return doc
+}
+
+func TestMarshalJSONAPI(t *testing.T) {
+	t.Parallel()
+
+	alice := &apiPerson{ID: "alice", Name: "Alice"}
+	bob := &apiPerson{ID: "bob", Name: "Bob"}
+	article := apiArticle{
+		ID:       1,
+		Title:    "JSON:API",
+		Author:   alice,
+		Comments: []apiComment{{ID: 5, Body: "first", Author: bob}, {ID: 6, Body: "second", Author: alice}},
+		Internal: "hidden",
+	}
+
+	person := func(p *apiPerson) map[string]interface{} {
+		return map[string]interface{}{"type": "people", "id": p.ID, "attributes": map[string]interface{}{"name": p.Name}}
+	}
+	ref := func(typ, id string) map[string]interface{} {
+		return map[string]interface{}{"type": typ, "id": id}
+	}
+
+	tests := map[string]struct {
+		target string
+		data   interface{}
+		want   map[string]interface{}
+	}{
+		"compound document": {
+			"/api/articles/1", &article,
+			map[string]interface{}{
+				"data": map[string]interface{}{
+					"type": "articles", "id": "1",
+					"attributes": map[string]interface{}{"title": "JSON:API"},
+					"relationships": map[string]interface{}{
+						"author":   map[string]interface{}{"data": ref("people", "alice")},
+						"editor":   map[string]interface{}{"data": nil},
+						"comments": map[string]interface{}{"data": []interface{}{ref("comments", "5"), ref("comments", "6")}},
+					},
+				},
+				"included": []interface{}{
+					person(alice),
+					map[string]interface{}{
+						"type": "comments", "id": "5",
+						"attributes":    map[string]interface{}{"body": "first"},
+						"relationships": map[string]interface{}{"author": map[string]interface{}{"data": ref("people", "bob")}},
+					},
+					person(bob),
+					map[string]interface{}{
+						"type": "comments", "id": "6",
+						"attributes":    map[string]interface{}{"body": "second"},
+						"relationships": map[string]interface{}{"author": map[string]interface{}{"data": ref("people", "alice")}},
+					},
+				},
+			},
+		},
+		"include and sparse fieldsets": {
+			"/api/articles/1?include=comments.author&fields[articles]=title,comments&fields[comments]=author", article,
+			map[string]interface{}{
+				"data": map[string]interface{}{
+					"type": "articles", "id": "1",
+					"attributes": map[string]interface{}{"title": "JSON:API"},
+					"relationships": map[string]interface{}{
+						"comments": map[string]interface{}{"data": []interface{}{ref("comments", "5"), ref("comments", "6")}},
+					},
+				},
+				"included": []interface{}{
+					map[string]interface{}{
+						"type": "comments", "id": "5",
+						"relationships": map[string]interface{}{"author": map[string]interface{}{"data": ref("people", "bob")}},
+					},
+					person(bob),
+					map[string]interface{}{
+						"type": "comments", "id": "6",
+						"relationships": map[string]interface{}{"author": map[string]interface{}{"data": ref("people", "alice")}},
+					},
+					person(alice),
+				},
+			},
+		},
+		"empty include": {
+			"/api/people?include=", []*apiPerson{alice, bob},
+			map[string]interface{}{
+				"data": []interface{}{person(alice), person(bob)},
+			},
+		},
+		"primary data is not included": {
+			"/api/comments?include=author", []apiComment{{ID: 5, Author: alice}},
+			map[string]interface{}{
+				"data": []interface{}{
+					map[string]interface{}{
+						"type": "comments", "id": "5",
+						"attributes":    map[string]interface{}{"body": ""},
+						"relationships": map[string]interface{}{"author": map[string]interface{}{"data": ref("people", "alice")}},
+					},
+				},
+				"included": []interface{}{person(alice)},
+			},
+		},
+		"null": {
+			"/api/articles/1", (*apiArticle)(nil),
+			map[string]interface{}{"data": nil},
+		},
+		"no resource": {
+			"/api/stats", map[string]int{"articles": 2},
+			map[string]interface{}{"meta": map[string]interface{}{"data": map[string]interface{}{"articles": float64(2)}}},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			doc := renderJSONAPI(t, tc.target, &wrapped.Response{Data: tc.data})
+			if !reflect.DeepEqual(doc, tc.want) {
+				got, _ := json.Marshal(doc)
+				want, _ := json.Marshal(tc.want)
+				t.Fatalf("expected JSON:API document '%s', got: '%s'", want, got)
+			}
+		})
+	}
+}
+
+func TestMarshalJSONAPI_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		res  *wrapped.Response
+		want []interface{}
+	}{
+		"message": {
+			&wrapped.Response{Code: http.StatusNotFound, Message: "article not found"},
+			[]interface{}{map[string]interface{}{"status": "404", "title": "Not Found", "detail": "article not found"}},
+		},
+		"default message": {
+			&wrapped.Response{Code: http.StatusServiceUnavailable},
+			[]interface{}{map[string]interface{}{"status": "503", "title": "Service Unavailable"}},
+		},
+		"data": {
+			&wrapped.Response{Code: http.StatusForbidden, Message: "denied", Data: map[string]string{"scope": "write"}},
+			[]interface{}{map[string]interface{}{
+				"status": "403", "title": "Forbidden", "detail": "denied",
+				"meta": map[string]interface{}{"data": map[string]interface{}{"scope": "write"}},
+			}},
+		},
+		"errorer": {
+			&wrapped.Response{Err: validationErrors{
+				{Detail: "must not be empty", Source: &rest.JSONAPIErrorSource{Pointer: "/data/attributes/title"}},
+				{Status: "400", Detail: "unknown field", Source: &rest.JSONAPIErrorSource{Parameter: "fields[articles]"}},
+			}},
+			[]interface{}{
+				map[string]interface{}{"status": "422", "detail": "must not be empty", "source": map[string]interface{}{"pointer": "/data/attributes/title"}},
+				map[string]interface{}{"status": "400", "detail": "unknown field", "source": map[string]interface{}{"parameter": "fields[articles]"}},
+			},
+		},
+		"data errors": {
+			&wrapped.Response{Code: http.StatusConflict, Data: []rest.JSONAPIError{{Code: "version_mismatch"}}},
+			[]interface{}{map[string]interface{}{"status": "409", "code": "version_mismatch"}},
+		},
+		"internal error": {
+			&wrapped.Response{Err: errors.New("database down")},
+			[]interface{}{map[string]interface{}{"status": "500", "title": "Internal Server Error"}},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			doc := renderJSONAPI(t, "/api/articles/1", tc.res)
+			want := map[string]interface{}{"errors": tc.want}
+			if !reflect.DeepEqual(doc, want) {
+				got, _ := json.Marshal(doc)
+				exp, _ := json.Marshal(want)
+				t.Fatalf("expected JSON:API document '%s', got: '%s'", exp, got)
+			}
+		})
+	}
+}
+
+func TestMarshalJSONAPI_Links(t *testing.T) {
+	t.Parallel()
+
+	doc := renderJSONAPI(t, "/api/articles?page=1", &wrapped.Response{
+		Data:  []apiPerson{},
+		Links: []wrapped.Link{{Rel: "next", Href: "?page=2"}},
+	})
+
+	want := map[string]interface{}{
+		"data":  []interface{}{},
+		"links": map[string]interface{}{"next": "http://example.com/api/articles?page=2"},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("expected JSON:API document '%v', got: '%v'", want, doc)
+	}
+}
+
+func TestMarshalJSONAPI_InvalidInclude(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		target string
+		path   string
+	}{
+		"unknown":            {"/api/articles/1?include=reviews", "reviews"},
+		"attribute":          {"/api/articles/1?include=title", "title"},
+		"unknown nested":     {"/api/articles/1?include=author,comments.editor", "comments.editor"},
+		"beyond attribute":   {"/api/articles/1?include=author.name", "author.name"},
+		"nested in relation": {"/api/articles/1?include=comments.author.friends", "comments.author.friends"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", tc.target, nil)
+			req.Header.Set("Accept", rest.MediaTypeJSONAPI)
+			rr := httptest.NewRecorder()
+			rest.Render(rr, req, &wrapped.Response{Data: &apiArticle{ID: 1}})
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf(`expected Code to be '%d', got: '%d'`, http.StatusBadRequest, rr.Code)
+			}
+
+			var doc map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("could not parse JSON:API document, err: '%s'", err)
+			}
+			want := []interface{}{map[string]interface{}{
+				"status": "400",
+				"title":  "Bad Request",
+				"detail": `cannot include "` + tc.path + `", it is no relationship`,
+				"source": map[string]interface{}{"parameter": "include"},
+			}}
+			if !reflect.DeepEqual(doc["errors"], want) {
+				t.Fatalf(`expected errors '%v', got: '%v'`, want, doc["errors"])
+			}
+		})
+	}
+}
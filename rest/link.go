@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// BaseURL returns the scheme, host and base path under which the client reached the server.
+//
+// The values of the X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-Prefix headers will be
+// preferred, make sure a trusted proxy sets or strips them.
+func BaseURL(r *http.Request) *url.URL {
+	u := &url.URL{Scheme: "http", Host: r.Host}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		u.Scheme = proto
+	}
+	if host := firstHeaderValue(r, "X-Forwarded-Host"); host != "" {
+		u.Host = host
+	}
+	if prefix := firstHeaderValue(r, "X-Forwarded-Prefix"); prefix != "" {
+		u.Path = "/" + strings.Trim(prefix, "/")
+	}
+
+	return u
+}
+
+// ResolveURL resolves href against the URL the client requested, see BaseURL:
+//
+//  - absolute URLs will be returned unchanged,
+//  - paths starting with "/" are relative to the base path,
+//  - other paths are relative to the requested resource.
+func ResolveURL(r *http.Request, href string) string {
+	ref, err := url.Parse(href)
+	if err != nil || ref.IsAbs() || ref.Host != "" {
+		return href
+	}
+
+	base := BaseURL(r)
+	if strings.HasPrefix(ref.Path, "/") {
+		ref.Path = path.Join(base.Path, ref.Path)
+		if strings.HasSuffix(href, "/") && !strings.HasSuffix(ref.Path, "/") {
+			ref.Path += "/"
+		}
+		return base.ResolveReference(ref).String()
+	}
+
+	base.Path = path.Join(base.Path, r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	base.RawQuery = r.URL.RawQuery
+
+	return base.ResolveReference(ref).String()
+}
+
+// LinkBuilder builds the Links of a wrapped.Response. All hrefs will be resolved with ResolveURL.
+//
+//  links := rest.NewLinkBuilder(r).
+//      Self().
+//      Add("next", "?page=3").
+//      Action("delete", http.MethodDelete, "", "Delete the invoice").
+//      Links()
+type LinkBuilder struct {
+	r     *http.Request
+	links []wrapped.Link
+}
+
+// NewLinkBuilder will create a LinkBuilder for r.
+func NewLinkBuilder(r *http.Request) *LinkBuilder {
+	return &LinkBuilder{r: r}
+}
+
+// Self adds a "self" link to the requested resource.
+func (b *LinkBuilder) Self() *LinkBuilder {
+	return b.Add("self", "")
+}
+
+// Add adds a link with rel to href.
+func (b *LinkBuilder) Add(rel, href string) *LinkBuilder {
+	b.links = append(b.links, wrapped.Link{Rel: rel, Href: ResolveURL(b.r, href)})
+	return b
+}
+
+// Action adds a link with rel which requires method.
+func (b *LinkBuilder) Action(rel, method, href, title string) *LinkBuilder {
+	if method == http.MethodGet {
+		method = ""
+	}
+
+	b.links = append(b.links, wrapped.Link{Rel: rel, Href: ResolveURL(b.r, href), Method: method, Title: title})
+	return b
+}
+
+// Links returns the built links.
+func (b *LinkBuilder) Links() []wrapped.Link {
+	return b.links
+}
+
+// Locator can be implemented by the data of Created and Accepted to set the Location header.
+type Locator interface {
+	// Location returns the URL of the resource, it will be resolved with ResolveURL.
+	Location() string
+}
+
+// CreatedAt responds like Created with the Location header set to href of the new resource.
+// The Links contain a "self" link to it, followed by links.
+func CreatedAt(w http.ResponseWriter, r *http.Request, href string, data interface{}, links ...wrapped.Link) {
+	responseWithLocation(w, r, http.StatusCreated, href, data, links)
+}
+
+// responseWithLocation renders data with the Location header set to href, the Location of data
+// (see Locator) or the already set header, in this order.
+func responseWithLocation(w http.ResponseWriter, r *http.Request, code int, href string, data interface{}, links []wrapped.Link) {
+	if locator, ok := data.(Locator); ok && href == "" {
+		href = locator.Location()
+	}
+	if href == "" {
+		href = w.Header().Get("Location")
+	}
+
+	res := &wrapped.Response{Code: code, Data: data, Links: links}
+	if href != "" {
+		location := ResolveURL(r, href)
+		w.Header().Set("Location", location)
+		res.Links = append([]wrapped.Link{{Rel: "self", Href: location}}, links...)
+	}
+
+	Render(w, r, res)
+}
+
+func firstHeaderValue(r *http.Request, key string) string {
+	value := r.Header.Get(key)
+	if i := strings.IndexByte(value, ','); i >= 0 {
+		value = value[:i]
+	}
+
+	return strings.TrimSpace(value)
+}
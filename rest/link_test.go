@@ -0,0 +1,177 @@
+package rest_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+func TestResolveURL(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		target string
+		header map[string]string
+		tls    bool
+		href   string
+		want   string
+	}{
+		"self":              {"/api/users/1?expand=groups", nil, false, "", "http://example.com/api/users/1?expand=groups"},
+		"absolute path":     {"/api/users/1", nil, false, "/api/groups", "http://example.com/api/groups"},
+		"relative path":     {"/api/users/1", nil, false, "2", "http://example.com/api/users/2"},
+		"sub resource":      {"/api/users/", nil, false, "1", "http://example.com/api/users/1"},
+		"query":             {"/api/users?page=2", nil, false, "?page=3", "http://example.com/api/users?page=3"},
+		"absolute url":      {"/api/users/1", nil, false, "https://other.com/x", "https://other.com/x"},
+		"tls":               {"/api/users/1", nil, true, "/api/users/2", "https://example.com/api/users/2"},
+		"forwarded proto":   {"/api/users/1", map[string]string{"X-Forwarded-Proto": "https, http"}, false, "/a", "https://example.com/a"},
+		"invalid proto":     {"/api/users/1", map[string]string{"X-Forwarded-Proto": "javascript"}, false, "/a", "http://example.com/a"},
+		"forwarded host":    {"/api/users/1", map[string]string{"X-Forwarded-Host": "api.example.com"}, false, "/a", "http://api.example.com/a"},
+		"forwarded prefix":  {"/api/users/1", map[string]string{"X-Forwarded-Prefix": "/v1/"}, false, "/api/users/2", "http://example.com/v1/api/users/2"},
+		"prefix with self":  {"/api/users/1", map[string]string{"X-Forwarded-Prefix": "/v1"}, false, "", "http://example.com/v1/api/users/1"},
+		"prefix trailing /": {"/api/users/1", map[string]string{"X-Forwarded-Prefix": "/v1"}, false, "/api/users/", "http://example.com/v1/api/users/"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", tc.target, nil)
+			req.Host = "example.com"
+			req.TLS = nil
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+
+			if got := rest.ResolveURL(req, tc.href); got != tc.want {
+				t.Fatalf(`expected URL to be '%s', got: '%s'`, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLinkBuilder(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/api/invoices/1", nil)
+	req.Host = "example.com"
+
+	links := rest.NewLinkBuilder(req).
+		Self().
+		Add("customer", "/api/customers/7").
+		Action("pay", http.MethodPost, "1/payments", "Pay the invoice").
+		Action("list", http.MethodGet, "/api/invoices", "").
+		Links()
+
+	want := []wrapped.Link{
+		{Rel: "self", Href: "http://example.com/api/invoices/1"},
+		{Rel: "customer", Href: "http://example.com/api/customers/7"},
+		{Rel: "pay", Href: "http://example.com/api/invoices/1/payments", Method: http.MethodPost, Title: "Pay the invoice"},
+		{Rel: "list", Href: "http://example.com/api/invoices"},
+	}
+
+	if len(links) != len(want) {
+		t.Fatalf(`expected %d links, got: '%v'`, len(want), links)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Fatalf(`expected link '%v', got: '%v'`, want[i], links[i])
+		}
+	}
+}
+
+func TestCreatedAt(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("POST", "/api/invoices", nil)
+	req.Host = "example.com"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr := httptest.NewRecorder()
+
+	rest.CreatedAt(rr, req, "/api/invoices/1", "invoice", wrapped.Link{Rel: "collection", Href: "/api/invoices"})
+	res := parseBodyToResponse(t, rr.Body)
+
+	if res.Code != http.StatusCreated {
+		t.Fatalf(`expected Code to be '%d', got: '%d'`, http.StatusCreated, res.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "https://example.com/api/invoices/1" {
+		t.Fatalf(`expected Location to be absolute, got: '%s'`, location)
+	}
+	if len(res.Links) != 2 || res.Links[0].Rel != "self" || res.Links[0].Href != "https://example.com/api/invoices/1" {
+		t.Fatalf(`expected self link first, got: '%v'`, res.Links)
+	}
+	if res.Data != "invoice" {
+		t.Fatalf(`expected Data to be 'invoice', got: '%v'`, res.Data)
+	}
+}
+
+type invoice struct {
+	ID string `json:"id"`
+}
+
+func (i invoice) Location() string {
+	return "/api/invoices/" + i.ID
+}
+
+func TestLocation(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		header   string
+		handler  func(w http.ResponseWriter, r *http.Request, data interface{})
+		data     interface{}
+		code     int
+		location string
+	}{
+		"created with locator":  {"", rest.Created, invoice{ID: "1"}, http.StatusCreated, "http://example.com/api/invoices/1"},
+		"accepted with locator": {"", rest.Accepted, invoice{ID: "1"}, http.StatusAccepted, "http://example.com/api/invoices/1"},
+		"locator wins":          {"/api/other", rest.Created, invoice{ID: "1"}, http.StatusCreated, "http://example.com/api/invoices/1"},
+		"created with header":   {"/api/invoices/2", rest.Created, "data", http.StatusCreated, "http://example.com/api/invoices/2"},
+		"accepted with header":  {"jobs/1", rest.Accepted, "data", http.StatusAccepted, "http://example.com/api/jobs/1"},
+		"absolute header":       {"https://jobs.example.com/1", rest.Accepted, nil, http.StatusAccepted, "https://jobs.example.com/1"},
+		"without location":      {"", rest.Created, "data", http.StatusCreated, ""},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("POST", "/api/invoices", nil)
+			req.Host = "example.com"
+			rr := httptest.NewRecorder()
+			if tc.header != "" {
+				rr.Header().Set("Location", tc.header)
+			}
+
+			tc.handler(rr, req, tc.data)
+			res := parseBodyToResponse(t, rr.Body)
+
+			if res.Code != tc.code {
+				t.Fatalf(`expected Code to be '%d', got: '%d'`, tc.code, res.Code)
+			}
+			if location := rr.Header().Get("Location"); location != tc.location {
+				t.Fatalf(`expected Location to be '%s', got: '%s'`, tc.location, location)
+			}
+
+			if tc.location == "" {
+				if len(res.Links) != 0 {
+					t.Fatalf(`expected no links, got: '%v'`, res.Links)
+				}
+				return
+			}
+			if len(res.Links) != 1 || res.Links[0] != (wrapped.Link{Rel: "self", Href: tc.location}) {
+				t.Fatalf(`expected self link to '%s', got: '%v'`, tc.location, res.Links)
+			}
+		})
+	}
+}
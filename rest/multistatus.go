@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// MultiStatusResult will be set as Data by MultiStatus.
+type MultiStatusResult struct {
+	// Items contains the parsed wrapped.Response of each item in order.
+	Items []*wrapped.Response `json:"items"`
+	// Summary counts the results of Items.
+	Summary MultiStatusSummary `json:"summary"`
+}
+
+// MultiStatusSummary counts the results of the items of a MultiStatusResult.
+type MultiStatusSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// MultiStatusOption configures MultiStatus.
+type MultiStatusOption func(*multiStatusOptions)
+
+type multiStatusOptions struct {
+	allOrNothing bool
+}
+
+// AllOrNothing switches MultiStatus to all-or-nothing semantics: if one item failed, the
+// response has the code of the first failed item and all succeeded items will be reported
+// with http.StatusFailedDependency. The handler is responsible to not apply any item, e.g.
+// by rolling back a transaction. The Summary counts the results before the rejection.
+// If all items succeeded, the response has http.StatusOK.
+func AllOrNothing() MultiStatusOption {
+	return func(o *multiStatusOptions) {
+		o.allOrNothing = true
+	}
+}
+
+// MultiStatus will render a MultiStatusResult of items with http.StatusMultiStatus, e.g. for
+// bulk create or update requests. Each item will be parsed like a response of Render, so its
+// code, message and data will be determined by Err, e.g. StatusCodeResponder.
+//
+//  items := make([]*wrapped.Response, len(users))
+//  for i, user := range users {
+//      if err := store.Create(user); err != nil {
+//          items[i] = &wrapped.Response{Err: err}
+//          continue
+//      }
+//      items[i] = &wrapped.Response{Code: http.StatusCreated, Data: user}
+//  }
+//  rest.MultiStatus(w, r, items)
+func MultiStatus(w http.ResponseWriter, r *http.Request, items []*wrapped.Response, opts ...MultiStatusOption) {
+	o := &multiStatusOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	result := MultiStatusResult{Items: make([]*wrapped.Response, 0, len(items))}
+	var firstFailed *wrapped.Response
+	for _, item := range items {
+		if item == nil {
+			item = &wrapped.Response{}
+		}
+		item.Parse(r.Context())
+
+		if item.Code >= http.StatusBadRequest {
+			result.Summary.Failed++
+			if firstFailed == nil {
+				firstFailed = item
+			}
+		} else {
+			result.Summary.Succeeded++
+		}
+		result.Items = append(result.Items, item)
+	}
+	result.Summary.Total = len(result.Items)
+
+	code, msg := http.StatusMultiStatus, ""
+	if o.allOrNothing {
+		code = http.StatusOK
+		if firstFailed != nil {
+			rejectSucceeded(r, &result)
+			code = firstFailed.Code
+			msg = fmt.Sprintf("%d of %d items failed", result.Summary.Failed, result.Summary.Total)
+		}
+	}
+
+	Render(w, r, &wrapped.Response{Code: code, Message: msg, Data: result})
+}
+
+// rejectSucceeded replaces the succeeded items of result with http.StatusFailedDependency.
+func rejectSucceeded(r *http.Request, result *MultiStatusResult) {
+	for i, item := range result.Items {
+		if item.Code >= http.StatusBadRequest {
+			continue
+		}
+
+		rejected := &wrapped.Response{
+			Code:    http.StatusFailedDependency,
+			Message: "not applied because other items failed",
+		}
+		rejected.Parse(r.Context())
+		result.Items[i] = rejected
+	}
+}
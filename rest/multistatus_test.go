@@ -0,0 +1,122 @@
+package rest_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+type conflictError struct{}
+
+func (conflictError) Error() string   { return "already exists" }
+func (conflictError) StatusCode() int { return http.StatusConflict }
+
+func multiStatusItems() []*wrapped.Response {
+	return []*wrapped.Response{
+		{Code: http.StatusCreated, Data: "alice"},
+		{Err: conflictError{}},
+		{Data: "carol"},
+		{Err: errors.New("database down")},
+	}
+}
+
+func TestMultiStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		items   []*wrapped.Response
+		opts    []rest.MultiStatusOption
+		code    int
+		msg     string
+		codes   []float64
+		summary map[string]interface{}
+	}{
+		"mixed": {
+			multiStatusItems(), nil, http.StatusMultiStatus, "",
+			[]float64{201, 409, 200, 500},
+			map[string]interface{}{"total": 4.0, "succeeded": 2.0, "failed": 2.0},
+		},
+		"empty": {
+			nil, nil, http.StatusMultiStatus, "",
+			[]float64{},
+			map[string]interface{}{"total": 0.0, "succeeded": 0.0, "failed": 0.0},
+		},
+		"all or nothing failed": {
+			multiStatusItems(), []rest.MultiStatusOption{rest.AllOrNothing()}, http.StatusConflict, "2 of 4 items failed",
+			[]float64{424, 409, 424, 500},
+			map[string]interface{}{"total": 4.0, "succeeded": 2.0, "failed": 2.0},
+		},
+		"all or nothing succeeded": {
+			multiStatusItems()[:1], []rest.MultiStatusOption{rest.AllOrNothing()}, http.StatusOK, "",
+			[]float64{201},
+			map[string]interface{}{"total": 1.0, "succeeded": 1.0, "failed": 0.0},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("POST", "/users", nil)
+			rr := httptest.NewRecorder()
+			rest.MultiStatus(rr, req, tc.items, tc.opts...)
+			res := parseBodyToResponse(t, rr.Body)
+
+			if rr.Code != tc.code || res.Code != tc.code {
+				t.Fatalf(`expected Code to be '%d', got: '%d'`, tc.code, rr.Code)
+			}
+			if res.Message != tc.msg {
+				t.Fatalf(`expected Message to be '%s', got: '%s'`, tc.msg, res.Message)
+			}
+
+			data, _ := res.Data.(map[string]interface{})
+			items, _ := data["items"].([]interface{})
+			if len(items) != len(tc.codes) {
+				t.Fatalf(`expected %d items, got: '%v'`, len(tc.codes), data["items"])
+			}
+			for i, code := range tc.codes {
+				item, _ := items[i].(map[string]interface{})
+				if item["code"] != code {
+					t.Fatalf(`expected item %d to have code '%v', got: '%v'`, i, code, item)
+				}
+			}
+
+			summary, _ := data["summary"].(map[string]interface{})
+			for key, value := range tc.summary {
+				if summary[key] != value {
+					t.Fatalf(`expected summary '%v', got: '%v'`, tc.summary, summary)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiStatus_Item(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("POST", "/users", nil)
+	rr := httptest.NewRecorder()
+	rest.MultiStatus(rr, req, multiStatusItems())
+	res := parseBodyToResponse(t, rr.Body)
+
+	data, _ := res.Data.(map[string]interface{})
+	items, _ := data["items"].([]interface{})
+	conflict, _ := items[1].(map[string]interface{})
+	if conflict["status"] != wrapped.StatusError || conflict["message"] != "already exists" {
+		t.Fatalf(`expected parsed conflict, got: '%v'`, conflict)
+	}
+	failed, _ := items[3].(map[string]interface{})
+	if failed["status"] != wrapped.StatusFail || failed["message"] != http.StatusText(http.StatusInternalServerError) {
+		t.Fatalf(`expected hidden internal error, got: '%v'`, failed)
+	}
+	created, _ := items[0].(map[string]interface{})
+	if created["status"] != wrapped.StatusSuccess || created["data"] != "alice" {
+		t.Fatalf(`expected created item, got: '%v'`, created)
+	}
+}
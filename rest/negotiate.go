@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// MediaTypeJSON is the default media type of Render, it will be encoded with MarshalFn.
+const MediaTypeJSON = "application/json"
+
+// EncoderFn encodes a parsed wrapped.Response for a media type.
+type EncoderFn func(r *http.Request, res *wrapped.Response) ([]byte, error)
+
+// Encoders contains the additional media types of Render. Render will select one of them if the
+// Accept header of the request prefers it over MediaTypeJSON.
+//
+// It is empty by default, so Render only responds with MediaTypeJSON. Media types have to be
+// registered before serving requests, e.g. MediaTypeHAL and MediaTypeJSONAPI:
+//
+//  rest.Encoders[rest.MediaTypeHAL] = rest.MarshalHAL
+//  rest.Encoders[rest.MediaTypeJSONAPI] = rest.MarshalJSONAPI
+var Encoders = map[string]EncoderFn{}
+
+// negotiate returns the media type of Encoders or MediaTypeJSON which r accepts with the highest
+// quality. MediaTypeJSON wins ties and will be returned if nothing matches.
+func negotiate(r *http.Request) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" || len(Encoders) == 0 {
+		return MediaTypeJSON
+	}
+
+	offers := make([]string, 0, len(Encoders)+1)
+	offers = append(offers, MediaTypeJSON)
+	for mediaType := range Encoders {
+		offers = append(offers, mediaType)
+	}
+	sort.Strings(offers[1:])
+
+	best, bestQ := MediaTypeJSON, 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// acceptQuality returns the quality of offer in the Accept header. The most specific range wins.
+func acceptQuality(accept, offer string) float64 {
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil && !errors.Is(err, mime.ErrInvalidMediaParameter) {
+			continue
+		}
+
+		s := -1
+		switch {
+		case mediaRange == offer:
+			s = 2
+		case mediaRange == "*/*":
+			s = 0
+		case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*")):
+			s = 1
+		}
+		if s <= specificity {
+			continue
+		}
+
+		specificity, q = s, 1
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+
+	return q
+}
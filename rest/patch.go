@@ -0,0 +1,597 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// Media types of patch documents accepted by Patch.
+const (
+	// MediaTypeMergePatch is a JSON Merge Patch, see https://tools.ietf.org/html/rfc7396.
+	MediaTypeMergePatch = "application/merge-patch+json"
+	// MediaTypeJSONPatch is a JSON Patch, see https://tools.ietf.org/html/rfc6902.
+	MediaTypeJSONPatch = "application/json-patch+json"
+)
+
+// PatchError will be returned by Patch, ApplyMergePatch and ApplyJSONPatch.
+//
+// It implements wrapped.StatusCodeResponder, wrapped.MsgResponder and wrapped.DataResponder,
+// so rest.Error will respond with Code and the PatchError as Data:
+//
+//  http.StatusBadRequest           the patch document is malformed
+//  http.StatusConflict             a "test" operation failed
+//  http.StatusUnsupportedMediaType the Content-Type is no patch document
+//  http.StatusUnprocessableEntity  a path does not exist or the result does not fit the resource
+type PatchError struct {
+	Code int `json:"-"`
+	// Operation is the 1-based index of the failed operation of a JSON Patch.
+	Operation int `json:"operation,omitempty"`
+	// Path is the JSON Pointer of the failed operation.
+	Path string `json:"path,omitempty"`
+	// Reason describes the problem.
+	Reason string `json:"reason"`
+}
+
+func (e *PatchError) Error() string {
+	msg := "patch"
+	if e.Operation > 0 {
+		msg += " operation " + strconv.Itoa(e.Operation)
+	}
+	if e.Path != "" {
+		msg += " at " + e.Path
+	}
+
+	return msg + ": " + e.Reason
+}
+
+// StatusCode implements wrapped.StatusCodeResponder.
+func (e *PatchError) StatusCode() int {
+	return e.Code
+}
+
+// Message implements wrapped.MsgResponder.
+func (e *PatchError) Message() string {
+	return e.Error()
+}
+
+// Data implements wrapped.DataResponder.
+func (e *PatchError) Data() interface{} {
+	return e
+}
+
+// Patch will apply the body of r to current, a pointer to the resource.
+//
+// The body can be a JSON Merge Patch (MediaTypeMergePatch) or a JSON Patch (MediaTypeJSONPatch).
+// The patch is applied to the JSON representation of current, so a null in a merge patch
+// removes a field while absent fields stay unchanged. The result must be a JSON object, it will
+// be unmarshaled into a copy of current which replaces current only if the whole patch succeeds.
+// Fields which are not marshaled to JSON, e.g. unexported fields or fields tagged with `json:"-"`,
+// keep their values. Removed fields will be zero.
+//
+// Problems are reported as *PatchError, errors of reading the body are returned unchanged.
+//
+//  user, _ := store.Get(id)
+//  if err := rest.Patch(r, &user); err != nil {
+//      rest.Error(w, r, err)
+//      return
+//  }
+//  store.Put(user)
+//  rest.Ok(w, r, user)
+func Patch(r *http.Request, current interface{}) error {
+	v := reflect.ValueOf(current)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("rest: Patch expects a non-nil pointer, got %T", current)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != MediaTypeMergePatch && mediaType != MediaTypeJSONPatch) {
+		return &PatchError{
+			Code:   http.StatusUnsupportedMediaType,
+			Reason: "Content-Type must be " + MediaTypeMergePatch + " or " + MediaTypeJSONPatch,
+		}
+	}
+
+	patch, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		var responder wrapped.StatusCodeResponder
+		if errors.As(err, &responder) {
+			return err
+		}
+		return &PatchError{Code: http.StatusBadRequest, Reason: "cannot read body"}
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return fmt.Errorf("rest: cannot marshal %T: %w", current, err)
+	}
+
+	if mediaType == MediaTypeMergePatch {
+		doc, err = ApplyMergePatch(doc, patch)
+	} else {
+		doc, err = ApplyJSONPatch(doc, patch)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(doc), []byte("{")) {
+		return &PatchError{Code: http.StatusUnprocessableEntity, Reason: "result must be a JSON object"}
+	}
+
+	patched := reflect.New(v.Elem().Type())
+	patched.Elem().Set(v.Elem())
+	clearJSONFields(patched.Elem())
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(patched.Interface()); err != nil {
+		return &PatchError{Code: http.StatusUnprocessableEntity, Reason: "result does not match resource: " + err.Error()}
+	}
+
+	v.Elem().Set(patched.Elem())
+	return nil
+}
+
+// clearJSONFields zeroes the fields of the struct v which are marshaled to JSON, so the patched
+// document replaces them without sharing maps, slices or pointers with the current value.
+func clearJSONFields(v reflect.Value) {
+	if v.Kind() != reflect.Struct {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		if _, _, skip := jsonField(field); skip {
+			continue
+		}
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			switch {
+			case value.Kind() == reflect.Struct:
+				clearJSONFields(value)
+				continue
+			case value.Kind() == reflect.Ptr && value.Type().Elem().Kind() == reflect.Struct && !value.IsNil():
+				if !value.CanSet() {
+					continue
+				}
+				embedded := reflect.New(value.Type().Elem())
+				embedded.Elem().Set(value.Elem())
+				clearJSONFields(embedded.Elem())
+				value.Set(embedded)
+				continue
+			}
+		}
+
+		if value.CanSet() {
+			value.Set(reflect.Zero(value.Type()))
+		}
+	}
+}
+
+// ApplyMergePatch applies the JSON Merge Patch patch to the JSON document doc.
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decodePatchJSON(doc)
+	if err != nil {
+		return nil, fmt.Errorf("rest: cannot decode document: %w", err)
+	}
+
+	p, err := decodePatchJSON(patch)
+	if err != nil {
+		return nil, &PatchError{Code: http.StatusBadRequest, Reason: "malformed merge patch: " + err.Error()}
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = mergePatch(t[key], value)
+	}
+
+	return t
+}
+
+// ApplyJSONPatch applies the JSON Patch patch to the JSON document doc. The operations will
+// be applied in order, if one fails the error will be returned and no result.
+func ApplyJSONPatch(doc, patch []byte) ([]byte, error) {
+	target, err := decodePatchJSON(doc)
+	if err != nil {
+		return nil, fmt.Errorf("rest: cannot decode document: %w", err)
+	}
+
+	var ops []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, &PatchError{Code: http.StatusBadRequest, Reason: "malformed JSON patch: expected array of operations"}
+	}
+
+	for i, raw := range ops {
+		op, err := parsePatchOperation(raw)
+		if err == nil {
+			target, err = op.apply(target)
+		}
+		if err != nil {
+			var patchErr *PatchError
+			if errors.As(err, &patchErr) {
+				patchErr.Operation = i + 1
+				if patchErr.Path == "" {
+					patchErr.Path = op.Path
+				}
+			}
+			return nil, err
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+// patchOperation is an operation of a JSON Patch.
+type patchOperation struct {
+	Op       string
+	Path     string
+	From     string
+	Value    interface{}
+	hasValue bool
+}
+
+func parsePatchOperation(raw map[string]json.RawMessage) (patchOperation, error) {
+	var op patchOperation
+
+	fields := map[string]*string{"op": &op.Op, "path": &op.Path, "from": &op.From}
+	for name, field := range fields {
+		value, ok := raw[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(value, field); err != nil {
+			return op, &PatchError{Code: http.StatusBadRequest, Reason: fmt.Sprintf("%q must be a string", name)}
+		}
+	}
+
+	if value, ok := raw["value"]; ok {
+		v, err := decodePatchJSON(value)
+		if err != nil {
+			return op, &PatchError{Code: http.StatusBadRequest, Reason: "malformed value"}
+		}
+		op.Value, op.hasValue = v, true
+	}
+
+	switch {
+	case raw["op"] == nil:
+		return op, &PatchError{Code: http.StatusBadRequest, Reason: `missing "op"`}
+	case raw["path"] == nil:
+		return op, &PatchError{Code: http.StatusBadRequest, Reason: `missing "path"`}
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if !op.hasValue {
+			return op, &PatchError{Code: http.StatusBadRequest, Reason: `missing "value"`}
+		}
+	case "move", "copy":
+		if raw["from"] == nil {
+			return op, &PatchError{Code: http.StatusBadRequest, Reason: `missing "from"`}
+		}
+	case "remove":
+	default:
+		return op, &PatchError{Code: http.StatusBadRequest, Reason: fmt.Sprintf("unknown op %q", op.Op)}
+	}
+
+	return op, nil
+}
+
+func (op patchOperation) apply(doc interface{}) (interface{}, error) {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return addValue(doc, path, op.Value)
+	case "remove":
+		doc, _, err = removeValue(doc, path)
+		return doc, err
+	case "replace":
+		if len(path) == 0 {
+			return op.Value, nil
+		}
+		if doc, _, err = removeValue(doc, path); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, op.Value)
+	case "test":
+		value, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(value, op.Value) {
+			return nil, &PatchError{Code: http.StatusConflict, Reason: "test failed"}
+		}
+		return doc, nil
+	}
+
+	// move and copy
+	var value interface{}
+	from, err := parseJSONPointer(op.From)
+	switch {
+	case err != nil:
+	case op.Op == "move":
+		if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+			return nil, &PatchError{Code: http.StatusUnprocessableEntity, Reason: "cannot move a value into itself"}
+		}
+		doc, value, err = removeValue(doc, from)
+	default:
+		value, err = getValue(doc, from)
+		value = copyJSON(value)
+	}
+	if err != nil {
+		var patchErr *PatchError
+		if errors.As(err, &patchErr) {
+			patchErr.Path = op.From
+		}
+		return nil, err
+	}
+
+	return addValue(doc, path, value)
+}
+
+// parseJSONPointer splits the JSON Pointer s into its unescaped reference tokens.
+func parseJSONPointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, pathError("invalid JSON pointer")
+	}
+
+	tokens := strings.Split(s[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+func pathError(reason string) *PatchError {
+	return &PatchError{Code: http.StatusUnprocessableEntity, Reason: reason}
+}
+
+// arrayIndex parses token as index of an array of length n, "-" references the end.
+func arrayIndex(token string, n int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return n, nil
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || strings.HasPrefix(token, "+") {
+		return 0, pathError(fmt.Sprintf("invalid array index %q", token))
+	}
+
+	upper := n - 1
+	if allowEnd {
+		upper = n
+	}
+	if i > upper {
+		return 0, pathError(fmt.Sprintf("array index %d out of bounds", i))
+	}
+
+	return i, nil
+}
+
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, pathError(fmt.Sprintf("member %q does not exist", token))
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, pathError(fmt.Sprintf("cannot reference %q in a scalar value", token))
+		}
+	}
+
+	return doc, nil
+}
+
+// updateParent calls fn with the parent of path and the last token, the returned parent
+// replaces the original one in doc.
+func updateParent(doc interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err = fn(parent, path[len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(path) == 1 {
+		return parent, nil
+	}
+
+	grandparent, err := getValue(doc, path[:len(path)-2])
+	if err != nil {
+		return nil, err
+	}
+
+	token := path[len(path)-2]
+	switch node := grandparent.(type) {
+	case map[string]interface{}:
+		node[token] = parent
+	case []interface{}:
+		i, _ := arrayIndex(token, len(node), false)
+		node[i] = parent
+	}
+
+	return doc, nil
+}
+
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return updateParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		default:
+			return nil, pathError(fmt.Sprintf("cannot add %q to a scalar value", token))
+		}
+	})
+}
+
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, pathError("cannot remove the whole document")
+	}
+
+	var removed interface{}
+	doc, err := updateParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, pathError(fmt.Sprintf("member %q does not exist", token))
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return append(node[:i:i], node[i+1:]...), nil
+		default:
+			return nil, pathError(fmt.Sprintf("cannot remove %q from a scalar value", token))
+		}
+	})
+
+	return doc, removed, err
+}
+
+// decodePatchJSON decodes data with json.Number, so numbers keep their precision.
+func decodePatchJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	return v, nil
+}
+
+func copyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = copyJSON(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = copyJSON(value)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// jsonEqual compares decoded JSON values, numbers are equal if their values are equal.
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,223 @@
+package rest_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		"replace":    {`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		"add":        {`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		"null":       {`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		"absent":     {`{"a":"b","b":"c"}`, `{}`, `{"a":"b","b":"c"}`},
+		"nested":     {`{"a":{"b":"c","d":"e"}}`, `{"a":{"d":null,"f":1}}`, `{"a":{"b":"c","f":1}}`},
+		"array":      {`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		"not object": {`{"a":"foo"}`, `["c"]`, `["c"]`},
+		"into value": {`{"a":"foo"}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+		"precision":  {`{"a":1}`, `{"b":12345678901234567890}`, `{"a":1,"b":12345678901234567890}`},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := rest.ApplyMergePatch([]byte(tc.doc), []byte(tc.patch))
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf(`expected '%s', got: '%s'`, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		"add":           {`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		"add array":     {`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		"add end":       {`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc"]}]`, `{"foo":["bar",["abc"]]}`},
+		"add null":      {`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":null}]`, `{"baz":null,"foo":"bar"}`},
+		"remove":        {`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		"remove array":  {`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		"replace":       {`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		"replace root":  {`{"foo":"bar"}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+		"move":          {`{"foo":{"bar":"baz"},"qux":{}}`, `[{"op":"move","from":"/foo/bar","path":"/qux/thud"}]`, `{"foo":{},"qux":{"thud":"baz"}}`},
+		"move array":    {`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		"copy":          {`{"a":{"b":[1]}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`, `{"a":{"b":[1]},"c":{"b":[1,2]}}`},
+		"test":          {`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		"escaped":       {`{"a/b":1,"m~n":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"replace","path":"/m~0n","value":3}]`, `{"m~n":3}`},
+		"nested array":  {`{"a":[[1],[2]]}`, `[{"op":"add","path":"/a/1/0","value":0}]`, `{"a":[[1],[0,2]]}`},
+		"no operations": {`{"a":1}`, `[]`, `{"a":1}`},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := rest.ApplyJSONPatch([]byte(tc.doc), []byte(tc.patch))
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf(`expected '%s', got: '%s'`, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatch_Error(t *testing.T) {
+	t.Parallel()
+
+	doc := `{"foo":{"bar":"baz"},"list":[1,2]}`
+
+	tests := map[string]struct {
+		patch string
+		code  int
+		msg   string
+	}{
+		"malformed":       {`{"op":"add"}`, http.StatusBadRequest, "patch: malformed JSON patch: expected array of operations"},
+		"missing op":      {`[{"path":"/a"}]`, http.StatusBadRequest, `patch operation 1 at /a: missing "op"`},
+		"unknown op":      {`[{"op":"merge","path":"/a"}]`, http.StatusBadRequest, `patch operation 1 at /a: unknown op "merge"`},
+		"missing value":   {`[{"op":"add","path":"/a"}]`, http.StatusBadRequest, `patch operation 1 at /a: missing "value"`},
+		"missing from":    {`[{"op":"copy","path":"/a"}]`, http.StatusBadRequest, `patch operation 1 at /a: missing "from"`},
+		"test failed":     {`[{"op":"add","path":"/a","value":1},{"op":"test","path":"/foo/bar","value":"qux"}]`, http.StatusConflict, "patch operation 2 at /foo/bar: test failed"},
+		"missing member":  {`[{"op":"remove","path":"/foo/qux"}]`, http.StatusUnprocessableEntity, `patch operation 1 at /foo/qux: member "qux" does not exist`},
+		"missing parent":  {`[{"op":"add","path":"/a/b","value":1}]`, http.StatusUnprocessableEntity, `patch operation 1 at /a/b: member "a" does not exist`},
+		"invalid pointer": {`[{"op":"test","path":"foo","value":1}]`, http.StatusUnprocessableEntity, "patch operation 1 at foo: invalid JSON pointer"},
+		"out of bounds":   {`[{"op":"add","path":"/list/3","value":1}]`, http.StatusUnprocessableEntity, "patch operation 1 at /list/3: array index 3 out of bounds"},
+		"leading zero":    {`[{"op":"replace","path":"/list/01","value":1}]`, http.StatusUnprocessableEntity, `patch operation 1 at /list/01: invalid array index "01"`},
+		"replace missing": {`[{"op":"replace","path":"/qux","value":1}]`, http.StatusUnprocessableEntity, `patch operation 1 at /qux: member "qux" does not exist`},
+		"move missing":    {`[{"op":"move","from":"/qux","path":"/a"}]`, http.StatusUnprocessableEntity, `patch operation 1 at /qux: member "qux" does not exist`},
+		"move into self":  {`[{"op":"move","from":"/foo","path":"/foo/bar"}]`, http.StatusUnprocessableEntity, "patch operation 1 at /foo/bar: cannot move a value into itself"},
+		"remove root":     {`[{"op":"remove","path":""}]`, http.StatusUnprocessableEntity, "patch operation 1: cannot remove the whole document"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := rest.ApplyJSONPatch([]byte(doc), []byte(tc.patch))
+
+			var patchErr *rest.PatchError
+			if !errors.As(err, &patchErr) {
+				t.Fatalf(`expected *rest.PatchError, got: '%v'`, err)
+			}
+			if patchErr.StatusCode() != tc.code {
+				t.Fatalf(`expected StatusCode to be '%d', got: '%d'`, tc.code, patchErr.StatusCode())
+			}
+			if patchErr.Error() != tc.msg {
+				t.Fatalf(`expected message '%s', got: '%s'`, tc.msg, patchErr.Error())
+			}
+		})
+	}
+}
+
+type patchAddress struct {
+	City string `json:"city"`
+}
+
+type patchUser struct {
+	Name    string        `json:"name"`
+	Email   *string       `json:"email"`
+	Tags    []string      `json:"tags"`
+	Address *patchAddress `json:"address,omitempty"`
+	Hash    string        `json:"-"`
+	version int
+}
+
+func TestPatch(t *testing.T) {
+	t.Parallel()
+
+	email := "alice@example.com"
+
+	tests := map[string]struct {
+		contentType string
+		body        string
+		code        int
+		want        patchUser
+	}{
+		"merge patch": {
+			rest.MediaTypeMergePatch, `{"name":"bob","address":{"city":"Berlin"}}`, http.StatusOK,
+			patchUser{Name: "bob", Email: &email, Tags: []string{"a"}, Address: &patchAddress{City: "Berlin"}, Hash: "h", version: 1},
+		},
+		"merge patch null": {
+			rest.MediaTypeMergePatch + "; charset=utf-8", `{"email":null,"tags":null}`, http.StatusOK,
+			patchUser{Name: "alice", Hash: "h", version: 1},
+		},
+		"json patch": {
+			rest.MediaTypeJSONPatch, `[{"op":"test","path":"/name","value":"alice"},{"op":"add","path":"/tags/-","value":"b"}]`, http.StatusOK,
+			patchUser{Name: "alice", Email: &email, Tags: []string{"a", "b"}, Hash: "h", version: 1},
+		},
+		"content type":  {"application/json", `{"name":"bob"}`, http.StatusUnsupportedMediaType, patchUser{}},
+		"malformed":     {rest.MediaTypeMergePatch, `{"name":`, http.StatusBadRequest, patchUser{}},
+		"test failed":   {rest.MediaTypeJSONPatch, `[{"op":"test","path":"/name","value":"bob"}]`, http.StatusConflict, patchUser{}},
+		"invalid path":  {rest.MediaTypeJSONPatch, `[{"op":"replace","path":"/address/city","value":"Berlin"}]`, http.StatusUnprocessableEntity, patchUser{}},
+		"wrong type":    {rest.MediaTypeMergePatch, `{"name":1}`, http.StatusUnprocessableEntity, patchUser{}},
+		"unknown field": {rest.MediaTypeMergePatch, `{"role":"admin"}`, http.StatusUnprocessableEntity, patchUser{}},
+		"null result":   {rest.MediaTypeMergePatch, `null`, http.StatusUnprocessableEntity, patchUser{}},
+		"replace root":  {rest.MediaTypeJSONPatch, `[{"op":"replace","path":"","value":null}]`, http.StatusUnprocessableEntity, patchUser{}},
+		"array result":  {rest.MediaTypeJSONPatch, `[{"op":"replace","path":"","value":[]}]`, http.StatusUnprocessableEntity, patchUser{}},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			user := patchUser{Name: "alice", Email: &email, Tags: []string{"a"}, Hash: "h", version: 1}
+			original := user
+			original.Tags = append([]string(nil), user.Tags...)
+
+			req := httptest.NewRequest("PATCH", "/users/1", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rr := httptest.NewRecorder()
+
+			if err := rest.Patch(req, &user); err != nil {
+				rest.Error(rr, req, err)
+			} else {
+				rest.Ok(rr, req, user)
+			}
+			res := parseBodyToResponse(t, rr.Body)
+
+			if rr.Code != tc.code || res.Code != tc.code {
+				t.Fatalf(`expected Code to be '%d', got: '%d' (%s)`, tc.code, rr.Code, res.Message)
+			}
+			if tc.code != http.StatusOK {
+				if !reflect.DeepEqual(user, original) {
+					t.Fatalf(`expected user to be unchanged, got: '%v'`, user)
+				}
+				return
+			}
+			if !reflect.DeepEqual(user, tc.want) {
+				t.Fatalf(`expected '%+v', got: '%+v'`, tc.want, user)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package query
+
+// Operator is a comparison operator of a filter expression.
+type Operator string
+
+// Operators of comparisons.
+const (
+	OpEq   Operator = "eq"
+	OpNe   Operator = "ne"
+	OpGt   Operator = "gt"
+	OpGe   Operator = "ge"
+	OpLt   Operator = "lt"
+	OpLe   Operator = "le"
+	OpIn   Operator = "in"
+	OpLike Operator = "like"
+)
+
+// LogicalOperator combines expressions.
+type LogicalOperator string
+
+// Logical operators.
+const (
+	And LogicalOperator = "and"
+	Or  LogicalOperator = "or"
+)
+
+// Expr is a node of a filter expression, either *Logical or *Comparison.
+type Expr interface {
+	// Accept calls the matching method of v.
+	Accept(v Visitor) error
+}
+
+// Visitor translates filter expressions, e.g. into SQL or in-memory predicates.
+type Visitor interface {
+	VisitLogical(e *Logical) error
+	VisitComparison(e *Comparison) error
+}
+
+// Logical combines two or more Operands with Op.
+type Logical struct {
+	Op       LogicalOperator
+	Operands []Expr
+}
+
+// Accept implements Expr.
+func (e *Logical) Accept(v Visitor) error {
+	return v.VisitLogical(e)
+}
+
+// Comparison compares a field with a value.
+//
+// Values contain the typed values of the comparison: string, int64 or float64, bool,
+// time.Time or nil for null. OpIn has one or more values, all other operators exactly one.
+type Comparison struct {
+	// Field is the name of the field in the expression.
+	Field string
+	// Column is the Column of the Field in the Schema.
+	Column string
+	Op     Operator
+	Values []interface{}
+}
+
+// Accept implements Expr.
+func (e *Comparison) Accept(v Visitor) error {
+	return v.VisitComparison(e)
+}
+
+// Value returns the first value of e.
+func (e *Comparison) Value() interface{} {
+	if len(e.Values) == 0 {
+		return nil
+	}
+
+	return e.Values[0]
+}
+
+// Sort is a field of a sort expression.
+type Sort struct {
+	// Field is the name of the field in the expression.
+	Field string
+	// Column is the Column of the Field in the Schema.
+	Column string
+	// Desc is true for descending order.
+	Desc bool
+}
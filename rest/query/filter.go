@@ -0,0 +1,324 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxDepth is the maximum nesting of parentheses in filter expressions.
+const MaxDepth = 16
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	// text is the raw text of the token.
+	text string
+	// value is the unquoted value of a tokenString.
+	value string
+	// pos is the 1-based position of the token.
+	pos int
+}
+
+// ParseFilter will parse and validate the filter expression s. It returns nil if s is empty.
+//
+//  status eq 'active' and (age ge 18 or role in ('admin','owner')) and name like 'a%'
+//
+// Comparisons have the form "field operator value". Strings are quoted with single quotes,
+// a quote is escaped by doubling it. Comparisons can be combined with "and", which binds
+// stronger than "or", and grouped with parentheses.
+func ParseFilter(s string, schema Schema) (Expr, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, schema: schema}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected token")
+	}
+
+	return expr, nil
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		start := i
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: start + 1})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: start + 1})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: start + 1})
+			i++
+		case c == '\'':
+			var value strings.Builder
+			i++
+			for {
+				if i >= len(s) {
+					return nil, &Error{Param: FilterParam, Position: start + 1, Token: s[start:], Reason: "unterminated string"}
+				}
+				if s[i] == '\'' {
+					if i+1 < len(s) && s[i+1] == '\'' {
+						value.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				value.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s[start:i], value: value.String(), pos: start + 1})
+		case c == '-' || isDigit(c):
+			i++
+			for i < len(s) && (isDigit(rune(s[i])) || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: s[start:i], pos: start + 1})
+		case c == '_' || unicode.IsLetter(c):
+			for i < len(s) && (s[i] == '_' || s[i] == '.' || isDigit(rune(s[i])) || unicode.IsLetter(rune(s[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[start:i], pos: start + 1})
+		default:
+			return nil, &Error{Param: FilterParam, Position: start + 1, Token: string(c), Reason: "unexpected character"}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(s) + 1}), nil
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+type parser struct {
+	tokens []token
+	i      int
+	schema Schema
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokenEOF {
+		p.i++
+	}
+
+	return tok
+}
+
+func (p *parser) errorf(tok token, reason string) *Error {
+	return &Error{Param: FilterParam, Position: tok.pos, Token: tok.text, Reason: reason}
+}
+
+func (p *parser) isKeyword(tok token, keyword string) bool {
+	return tok.kind == tokenIdent && strings.EqualFold(tok.text, keyword)
+}
+
+// parseOr parses: and ("or" and)*.
+func (p *parser) parseOr() (Expr, error) {
+	return p.parseLogical(Or, p.parseAnd)
+}
+
+// parseAnd parses: primary ("and" primary)*.
+func (p *parser) parseAnd() (Expr, error) {
+	return p.parseLogical(And, p.parsePrimary)
+}
+
+func (p *parser) parseLogical(op LogicalOperator, operand func() (Expr, error)) (Expr, error) {
+	expr, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Expr{expr}
+	for p.isKeyword(p.peek(), string(op)) {
+		p.next()
+		expr, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, expr)
+	}
+
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+
+	return &Logical{Op: op, Operands: operands}, nil
+}
+
+// parsePrimary parses: "(" or-expression ")" | comparison.
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.peek()
+	if tok.kind != tokenLParen {
+		return p.parseComparison()
+	}
+
+	p.next()
+	if p.depth++; p.depth > MaxDepth {
+		return nil, p.errorf(tok, "expression nested too deeply")
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if closing := p.next(); closing.kind != tokenRParen {
+		return nil, p.errorf(closing, "expected ')'")
+	}
+	p.depth--
+
+	return expr, nil
+}
+
+// parseComparison parses: field operator value | field "in" "(" value ("," value)* ")".
+func (p *parser) parseComparison() (Expr, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent || p.isKeyword(tok, string(And)) || p.isKeyword(tok, string(Or)) {
+		return nil, p.errorf(tok, "expected field")
+	}
+
+	field, ok := p.schema[tok.text]
+	if !ok {
+		return nil, p.errorf(tok, "unknown field")
+	}
+
+	opTok := p.next()
+	if opTok.kind != tokenIdent {
+		return nil, p.errorf(opTok, "expected operator")
+	}
+
+	op := Operator(strings.ToLower(opTok.text))
+	if !isOperator(op) {
+		return nil, p.errorf(opTok, "unknown operator")
+	}
+	if !field.allows(op) {
+		return nil, p.errorf(opTok, "operator not allowed for field")
+	}
+
+	expr := &Comparison{Field: tok.text, Column: field.column(tok.text), Op: op}
+
+	if op != OpIn {
+		value, err := p.parseValue(field, op)
+		if err != nil {
+			return nil, err
+		}
+		expr.Values = []interface{}{value}
+		return expr, nil
+	}
+
+	if open := p.next(); open.kind != tokenLParen {
+		return nil, p.errorf(open, "expected '('")
+	}
+	for {
+		value, err := p.parseValue(field, op)
+		if err != nil {
+			return nil, err
+		}
+		expr.Values = append(expr.Values, value)
+
+		sep := p.next()
+		if sep.kind == tokenRParen {
+			return expr, nil
+		}
+		if sep.kind != tokenComma {
+			return nil, p.errorf(sep, "expected ',' or ')'")
+		}
+	}
+}
+
+// parseValue parses a value of the type of field.
+func (p *parser) parseValue(field Field, op Operator) (interface{}, error) {
+	tok := p.next()
+
+	if p.isKeyword(tok, "null") {
+		if op != OpEq && op != OpNe {
+			return nil, p.errorf(tok, "null is only allowed with eq and ne")
+		}
+		return nil, nil
+	}
+
+	switch field.Type {
+	case TypeString:
+		if tok.kind == tokenString {
+			return tok.value, nil
+		}
+		return nil, p.errorf(tok, "expected string")
+	case TypeNumber:
+		if tok.kind == tokenNumber {
+			if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+				return i, nil
+			}
+			if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, p.errorf(tok, "expected number")
+	case TypeBool:
+		if p.isKeyword(tok, "true") || p.isKeyword(tok, "false") {
+			return strings.EqualFold(tok.text, "true"), nil
+		}
+		return nil, p.errorf(tok, "expected true or false")
+	case TypeTime:
+		if tok.kind == tokenString {
+			if t, err := time.Parse(time.RFC3339, tok.value); err == nil {
+				return t, nil
+			}
+			if t, err := time.Parse("2006-01-02", tok.value); err == nil {
+				return t, nil
+			}
+		}
+		return nil, p.errorf(tok, "expected time")
+	default:
+		return nil, p.errorf(tok, "unsupported field type")
+	}
+}
+
+func isOperator(op Operator) bool {
+	switch op {
+	case OpEq, OpNe, OpGt, OpGe, OpLt, OpLe, OpIn, OpLike:
+		return true
+	default:
+		return false
+	}
+}
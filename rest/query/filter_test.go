@@ -0,0 +1,164 @@
+package query_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest/query"
+)
+
+var schema = query.Schema{
+	"name":     {Type: query.TypeString, Sortable: true},
+	"status":   {Type: query.TypeString, Operators: []query.Operator{query.OpEq, query.OpIn}},
+	"age":      {Type: query.TypeNumber, Sortable: true, Column: "age_years"},
+	"score":    {Type: query.TypeNumber},
+	"active":   {Type: query.TypeBool},
+	"created":  {Type: query.TypeTime, Sortable: true},
+	"owner.id": {Type: query.TypeNumber},
+}
+
+// printer renders an Expr with explicit parentheses and typed values.
+type printer struct {
+	b strings.Builder
+}
+
+func (p *printer) VisitLogical(e *query.Logical) error {
+	p.b.WriteString("(")
+	for i, operand := range e.Operands {
+		if i > 0 {
+			p.b.WriteString(" " + string(e.Op) + " ")
+		}
+		if err := operand.Accept(p); err != nil {
+			return err
+		}
+	}
+	p.b.WriteString(")")
+	return nil
+}
+
+func (p *printer) VisitComparison(e *query.Comparison) error {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		if t, ok := v.(time.Time); ok {
+			values[i] = "time.Time:" + t.Format(time.RFC3339)
+			continue
+		}
+		values[i] = fmt.Sprintf("%T:%v", v, v)
+	}
+	fmt.Fprintf(&p.b, "%s %s %s", e.Column, e.Op, strings.Join(values, ","))
+	return nil
+}
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		filter string
+		want   string
+	}{
+		"empty":        {"  ", ""},
+		"string":       {"name eq 'alice'", "name eq string:alice"},
+		"escaped":      {"name eq 'O''Brien'", "name eq string:O'Brien"},
+		"integer":      {"age gt 18", "age_years gt int64:18"},
+		"float":        {"score le -1.5", "score le float64:-1.5"},
+		"bool":         {"active eq TRUE", "active eq bool:true"},
+		"null":         {"name ne null", "name ne <nil>:<nil>"},
+		"time":         {"created ge '2021-01-02T03:04:05Z'", "created ge time.Time:2021-01-02T03:04:05Z"},
+		"date":         {"created lt '2021-01-02'", "created lt time.Time:2021-01-02T00:00:00Z"},
+		"in":           {"status in ('a', 'b')", "status in string:a,string:b"},
+		"like":         {"name like 'a%'", "name like string:a%"},
+		"nested field": {"owner.id eq 1", "owner.id eq int64:1"},
+		"and binds stronger": {
+			"name eq 'a' or age gt 1 and active eq true",
+			"(name eq string:a or (age_years gt int64:1 and active eq bool:true))",
+		},
+		"grouping": {
+			"(name eq 'a' or age gt 1) AND active eq true",
+			"((name eq string:a or age_years gt int64:1) and active eq bool:true)",
+		},
+		"flattened": {
+			"name eq 'a' and age gt 1 and score lt 2",
+			"(name eq string:a and age_years gt int64:1 and score lt int64:2)",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			expr, err := query.ParseFilter(tc.filter, schema)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+
+			var p printer
+			if expr != nil {
+				if err := expr.Accept(&p); err != nil {
+					t.Fatalf(`did not expect error, got: '%s'`, err)
+				}
+			}
+			if got := p.b.String(); got != tc.want {
+				t.Fatalf(`expected '%s', got: '%s'`, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseFilter_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		filter   string
+		position int
+		token    string
+		reason   string
+	}{
+		"unknown field":        {"name eq 'a' and email eq 'b'", 17, "email", "unknown field"},
+		"unknown operator":     {"name equals 'a'", 6, "equals", "unknown operator"},
+		"operator not allowed": {"status ne 'a'", 8, "ne", "operator not allowed for field"},
+		"like on number":       {"age like 1", 5, "like", "operator not allowed for field"},
+		"missing operator":     {"name", 5, "", "expected operator"},
+		"expected string":      {"name eq 1", 9, "1", "expected string"},
+		"expected number":      {"age eq '1'", 8, "'1'", "expected number"},
+		"invalid number":       {"age eq 1.2.3", 8, "1.2.3", "expected number"},
+		"expected bool":        {"active eq 1", 11, "1", "expected true or false"},
+		"expected time":        {"created gt 'yesterday'", 12, "'yesterday'", "expected time"},
+		"null with gt":         {"age gt null", 8, "null", "null is only allowed with eq and ne"},
+		"unterminated string":  {"name eq 'a", 9, "'a", "unterminated string"},
+		"unexpected character": {"name eq 'a' & age eq 1", 13, "&", "unexpected character"},
+		"missing parenthesis":  {"(name eq 'a'", 13, "", "expected ')'"},
+		"trailing token":       {"name eq 'a')", 12, ")", "unexpected token"},
+		"dangling and":         {"name eq 'a' and", 16, "", "expected field"},
+		"keyword as field":     {"and eq 1", 1, "and", "expected field"},
+		"in without list":      {"status in 'a'", 11, "'a'", "expected '('"},
+		"in separator":         {"status in ('a' 'b')", 16, "'b'", "expected ',' or ')'"},
+		"nested too deeply":    {strings.Repeat("(", query.MaxDepth+1) + "age eq 1" + strings.Repeat(")", query.MaxDepth+1), query.MaxDepth + 1, "(", "expression nested too deeply"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := query.ParseFilter(tc.filter, schema)
+
+			var queryErr *query.Error
+			if !errors.As(err, &queryErr) {
+				t.Fatalf(`expected query.Error, got: '%v'`, err)
+			}
+			if queryErr.Param != query.FilterParam {
+				t.Fatalf(`expected Param to be '%s', got: '%s'`, query.FilterParam, queryErr.Param)
+			}
+			if queryErr.Position != tc.position || queryErr.Token != tc.token || queryErr.Reason != tc.reason {
+				t.Fatalf(`expected error '%s' at %d with '%s', got: '%s' at %d with '%s'`,
+					tc.reason, tc.position, tc.token, queryErr.Reason, queryErr.Position, queryErr.Token)
+			}
+		})
+	}
+}
@@ -0,0 +1,461 @@
+package query
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+)
+
+// List is a page of items, see Query.Apply.
+type List struct {
+	// Items is a slice of the same type as the queried items.
+	Items interface{} `json:"items"`
+	// Total is the number of items which match the filter.
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// ServeSlice will apply the Query of r to items and responds with rest.Ok and the List.
+// Invalid query parameters will be rejected with http.StatusBadRequest.
+//
+//  func listUsers(w http.ResponseWriter, r *http.Request) {
+//      query.ServeSlice(w, r, userSchema, users)
+//  }
+func ServeSlice(w http.ResponseWriter, r *http.Request, schema Schema, items interface{}) {
+	q, err := Parse(r, schema)
+	if err != nil {
+		rest.Error(w, r, err)
+		return
+	}
+
+	list, err := q.Apply(items)
+	if err != nil {
+		rest.Error(w, r, err)
+		return
+	}
+
+	rest.Ok(w, r, list)
+}
+
+// Apply will filter, sort and page items in memory. Items must be a slice of structs or pointers
+// to structs. A Limit of 0 returns all items after Offset.
+//
+// The Column of a Field refers to the JSON name or the name of a struct field, nested fields
+// are separated by dots, e.g. "owner.name". Nil pointers are null. Strings will be compared
+// case-sensitive, "like" supports the wildcards "%" and "_". "ne" matches null, see Query.SQL
+// for the differences to SQL.
+//
+// An error will be returned if a Column does not exist or its type does not match the Schema.
+func (q *Query) Apply(items interface{}) (*List, error) {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("query: expected slice, got %T", items)
+	}
+
+	matched := make([]reflect.Value, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		if q.Filter != nil {
+			m := &matcher{item: item}
+			if err := q.Filter.Accept(m); err != nil {
+				return nil, err
+			}
+			if !m.result {
+				continue
+			}
+		}
+		matched = append(matched, item)
+	}
+
+	if err := sortValues(matched, q.Sort); err != nil {
+		return nil, err
+	}
+
+	total := len(matched)
+	start, end := q.Offset, total
+	if start > total {
+		start = total
+	}
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	page := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), end-start, end-start)
+	for i, item := range matched[start:end] {
+		page.Index(i).Set(item)
+	}
+
+	return &List{Items: page.Interface(), Total: total, Limit: q.Limit, Offset: q.Offset}, nil
+}
+
+func sortValues(items []reflect.Value, sorts []Sort) error {
+	if len(sorts) == 0 {
+		return nil
+	}
+
+	var err error
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, s := range sorts {
+			a, errA := columnValue(items[i], s.Column)
+			b, errB := columnValue(items[j], s.Column)
+			if errA != nil || errB != nil {
+				if err == nil {
+					err = errA
+					if err == nil {
+						err = errB
+					}
+				}
+				return false
+			}
+
+			c, cmpErr := compareColumns(a, b)
+			if cmpErr != nil {
+				err = fmt.Errorf("query: cannot sort column %q: %w", s.Column, cmpErr)
+				return false
+			}
+			if c != 0 {
+				return (c < 0) != s.Desc
+			}
+		}
+		return false
+	})
+
+	return err
+}
+
+// matcher is a Visitor which evaluates an Expr for item.
+type matcher struct {
+	item   reflect.Value
+	result bool
+}
+
+func (m *matcher) VisitLogical(e *Logical) error {
+	for _, operand := range e.Operands {
+		if err := operand.Accept(m); err != nil {
+			return err
+		}
+		if m.result == (e.Op == Or) {
+			return nil
+		}
+	}
+
+	m.result = e.Op == And
+	return nil
+}
+
+func (m *matcher) VisitComparison(e *Comparison) error {
+	v, err := columnValue(m.item, e.Column)
+	if err != nil {
+		return err
+	}
+
+	m.result = false
+	for _, value := range e.Values {
+		ok, err := compareOp(v, e.Op, value)
+		if err != nil {
+			return fmt.Errorf("query: cannot compare column %q: %w", e.Column, err)
+		}
+		if ok {
+			m.result = true
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// compareOp applies op to the column value v and value, an invalid v is null.
+func compareOp(v reflect.Value, op Operator, value interface{}) (bool, error) {
+	if value == nil || !v.IsValid() {
+		equal := value == nil && !v.IsValid()
+		switch op {
+		case OpEq, OpIn:
+			return equal, nil
+		case OpNe:
+			return !equal, nil
+		default:
+			return false, nil
+		}
+	}
+
+	if op == OpLike {
+		s, ok := value.(string)
+		if !ok || v.Kind() != reflect.String {
+			return false, fmt.Errorf("like requires strings, got %s", v.Type())
+		}
+		return matchLike(v.String(), s), nil
+	}
+
+	c, err := compareValue(v, value)
+	if err != nil {
+		return false, err
+	}
+
+	switch op {
+	case OpEq, OpIn:
+		return c == 0, nil
+	case OpNe:
+		return c != 0, nil
+	case OpGt:
+		return c > 0, nil
+	case OpGe:
+		return c >= 0, nil
+	case OpLt:
+		return c < 0, nil
+	case OpLe:
+		return c <= 0, nil
+	default:
+		return false, fmt.Errorf("unsupported operator %q", op)
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// compareValue compares the column value v with a value of a Comparison.
+func compareValue(v reflect.Value, value interface{}) (int, error) {
+	switch value := value.(type) {
+	case string:
+		if v.Kind() == reflect.String {
+			return strings.Compare(v.String(), value), nil
+		}
+	case bool:
+		if v.Kind() == reflect.Bool {
+			return compareBool(v.Bool(), value), nil
+		}
+	case time.Time:
+		if v.Type() == timeType && v.CanInterface() {
+			return compareTime(v.Interface().(time.Time), value), nil
+		}
+	case int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareInt(v.Int(), value), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if value < 0 {
+				return 1, nil
+			}
+			return compareUint(v.Uint(), uint64(value)), nil
+		case reflect.Float32, reflect.Float64:
+			return compareFloat(v.Float(), float64(value)), nil
+		}
+	case float64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareFloat(float64(v.Int()), value), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return compareFloat(float64(v.Uint()), value), nil
+		case reflect.Float32, reflect.Float64:
+			return compareFloat(v.Float(), value), nil
+		}
+	}
+
+	return 0, fmt.Errorf("type %s does not match value %v", v.Type(), value)
+}
+
+// compareColumns compares two column values for sorting, null comes first.
+func compareColumns(a, b reflect.Value) (int, error) {
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return 0, nil
+	case !a.IsValid():
+		return -1, nil
+	case !b.IsValid():
+		return 1, nil
+	}
+
+	switch {
+	case b.Type() == timeType:
+		return compareValue(a, b.Interface())
+	case b.Kind() == reflect.String:
+		return compareValue(a, b.String())
+	case b.Kind() == reflect.Bool:
+		return compareValue(a, b.Bool())
+	case b.Kind() >= reflect.Int && b.Kind() <= reflect.Int64:
+		return compareValue(a, b.Int())
+	case b.Kind() >= reflect.Uint && b.Kind() <= reflect.Uintptr:
+		return compareValue(a, float64(b.Uint()))
+	case b.Kind() == reflect.Float32 || b.Kind() == reflect.Float64:
+		return compareValue(a, b.Float())
+	default:
+		return 0, fmt.Errorf("type %s is not sortable", b.Type())
+	}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// matchLike reports if s matches the LIKE pattern, "%" matches any sequence and "_" any character.
+func matchLike(s, pattern string) bool {
+	str, pat := []rune(s), []rune(pattern)
+
+	// match[j] reports if str[:i] matches pat[:j]
+	match := make([]bool, len(pat)+1)
+	match[0] = true
+	for j := 1; j <= len(pat) && pat[j-1] == '%'; j++ {
+		match[j] = true
+	}
+
+	for i := 1; i <= len(str); i++ {
+		prev := match[0]
+		match[0] = false
+		for j := 1; j <= len(pat); j++ {
+			current := match[j]
+			switch pat[j-1] {
+			case '%':
+				match[j] = match[j-1] || match[j]
+			case '_':
+				match[j] = prev
+			default:
+				match[j] = prev && pat[j-1] == str[i-1]
+			}
+			prev = current
+		}
+	}
+
+	return match[len(pat)]
+}
+
+// columnValue returns the value of column in item. An invalid value will be returned for null.
+func columnValue(item reflect.Value, column string) (reflect.Value, error) {
+	v := item
+	for _, name := range strings.Split(column, ".") {
+		v = indirect(v)
+		if !v.IsValid() {
+			return v, nil
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("query: column %q: %s is no struct", column, v.Type())
+		}
+
+		index, ok := lookupField(v.Type(), name)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("query: column %q: unknown field %q in %s", column, name, v.Type())
+		}
+
+		v, ok = fieldByIndex(v, index)
+		if !ok {
+			return reflect.Value{}, nil
+		}
+	}
+
+	return indirect(v), nil
+}
+
+// indirect dereferences pointers and interfaces, it returns an invalid value for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but returns false for nil embedded pointers.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			v = indirect(v)
+			if !v.IsValid() {
+				return v, false
+			}
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// lookupField returns the index of the field of t with the JSON name or field name name.
+func lookupField(t reflect.Type, name string) ([]int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+
+		if field.Anonymous && tag == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if index, ok := lookupField(embedded, name); ok {
+					return append([]int{i}, index...), true
+				}
+			}
+			continue
+		}
+
+		if field.PkgPath == "" && (tag == name || (tag == "" && field.Name == name)) {
+			return []int{i}, true
+		}
+	}
+
+	if field, ok := t.FieldByName(name); ok && field.PkgPath == "" {
+		return field.Index, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,203 @@
+package query_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest/query"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+type memoryOwner struct {
+	ID int `json:"id"`
+}
+
+type memoryUser struct {
+	Name    string       `json:"name"`
+	Status  string       `json:"status"`
+	Age     uint8        `json:"age"`
+	Score   float64      `json:"score"`
+	Active  bool         `json:"active"`
+	Created time.Time    `json:"created"`
+	Owner   *memoryOwner `json:"owner"`
+}
+
+var memorySchema = query.Schema{
+	"name":     {Type: query.TypeString, Sortable: true},
+	"status":   {Type: query.TypeString},
+	"age":      {Type: query.TypeNumber, Sortable: true},
+	"score":    {Type: query.TypeNumber, Sortable: true},
+	"active":   {Type: query.TypeBool, Sortable: true},
+	"created":  {Type: query.TypeTime, Sortable: true, Column: "Created"},
+	"owner.id": {Type: query.TypeNumber, Sortable: true},
+}
+
+func memoryUsers() []memoryUser {
+	day := func(d int) time.Time {
+		return time.Date(2021, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	return []memoryUser{
+		{Name: "alice", Status: "active", Age: 30, Score: 1.5, Active: true, Created: day(3), Owner: &memoryOwner{ID: 1}},
+		{Name: "bob", Status: "blocked", Age: 17, Score: 2, Created: day(1)},
+		{Name: "carol", Status: "active", Age: 45, Score: -1, Active: true, Created: day(2), Owner: &memoryOwner{ID: 2}},
+		{Name: "dave", Status: "invited", Age: 30, Score: 0.5, Created: day(4), Owner: &memoryOwner{ID: 1}},
+	}
+}
+
+func names(t *testing.T, list *query.List) []string {
+	users, ok := list.Items.([]memoryUser)
+	if !ok {
+		t.Fatalf(`expected Items to be []memoryUser, got: '%T'`, list.Items)
+	}
+
+	out := []string{}
+	for _, u := range users {
+		out = append(out, u.Name)
+	}
+	return out
+}
+
+func TestQuery_Apply(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		filter string
+		sort   string
+		limit  int
+		offset int
+		want   []string
+		total  int
+	}{
+		"all":          {"", "", 0, 0, []string{"alice", "bob", "carol", "dave"}, 4},
+		"eq":           {"status eq 'active'", "", 0, 0, []string{"alice", "carol"}, 2},
+		"ne":           {"status ne 'active'", "", 0, 0, []string{"bob", "dave"}, 2},
+		"number":       {"age ge 30 and score lt 1.5", "", 0, 0, []string{"carol", "dave"}, 2},
+		"negative":     {"age gt -1 and score le -1", "", 0, 0, []string{"carol"}, 1},
+		"bool":         {"active eq false", "", 0, 0, []string{"bob", "dave"}, 2},
+		"time":         {"created gt '2021-01-02'", "", 0, 0, []string{"alice", "dave"}, 2},
+		"in":           {"status in ('blocked', 'invited')", "", 0, 0, []string{"bob", "dave"}, 2},
+		"like":         {"name like '%a_e%'", "", 0, 0, []string{"dave"}, 1},
+		"like prefix":  {"name like 'c%'", "", 0, 0, []string{"carol"}, 1},
+		"or":           {"name eq 'bob' or (age eq 30 and active eq true)", "", 0, 0, []string{"alice", "bob"}, 2},
+		"nested":       {"owner.id eq 1", "", 0, 0, []string{"alice", "dave"}, 2},
+		"null":         {"owner.id eq null", "", 0, 0, []string{"bob"}, 1},
+		"not null":     {"owner.id ne null", "", 0, 0, []string{"alice", "carol", "dave"}, 3},
+		"sort":         {"", "-age,name", 0, 0, []string{"carol", "alice", "dave", "bob"}, 4},
+		"sort time":    {"", "created", 0, 0, []string{"bob", "carol", "alice", "dave"}, 4},
+		"sort bool":    {"", "-active,score", 0, 0, []string{"carol", "alice", "dave", "bob"}, 4},
+		"sort null":    {"", "owner.id,name", 0, 0, []string{"bob", "alice", "dave", "carol"}, 4},
+		"page":         {"", "name", 2, 1, []string{"bob", "carol"}, 4},
+		"last page":    {"", "name", 2, 3, []string{"dave"}, 4},
+		"out of range": {"", "", 2, 10, []string{}, 4},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := query.ParseFilter(tc.filter, memorySchema)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			sort, err := query.ParseSort(tc.sort, memorySchema)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+
+			q := &query.Query{Filter: filter, Sort: sort, Limit: tc.limit, Offset: tc.offset}
+			list, err := q.Apply(memoryUsers())
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+
+			if got := names(t, list); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf(`expected '%v', got: '%v'`, tc.want, got)
+			}
+			if list.Total != tc.total {
+				t.Fatalf(`expected Total to be %d, got: %d`, tc.total, list.Total)
+			}
+		})
+	}
+}
+
+func TestQuery_Apply_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		items interface{}
+		q     *query.Query
+	}{
+		"no slice": {memoryUser{}, &query.Query{}},
+		"unknown column": {memoryUsers(), &query.Query{
+			Filter: &query.Comparison{Column: "email", Op: query.OpEq, Values: []interface{}{"a"}},
+		}},
+		"type mismatch": {memoryUsers(), &query.Query{
+			Filter: &query.Comparison{Column: "name", Op: query.OpEq, Values: []interface{}{int64(1)}},
+		}},
+		"unknown sort column": {memoryUsers(), &query.Query{Sort: []query.Sort{{Column: "email"}}}},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := tc.q.Apply(tc.items); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestServeSlice(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		query url.Values
+		code  int
+		total float64
+		items int
+	}{
+		"default":       {url.Values{}, http.StatusOK, 4, 4},
+		"filter":        {url.Values{"filter": {"status eq 'active'"}, "sort": {"-name"}, "limit": {"1"}}, http.StatusOK, 2, 1},
+		"invalid":       {url.Values{"filter": {"email eq 'a'"}}, http.StatusBadRequest, 0, 0},
+		"limit too big": {url.Values{"limit": {"1000"}}, http.StatusBadRequest, 0, 0},
+		"zero limit":    {url.Values{"limit": {"0"}}, http.StatusBadRequest, 0, 0},
+		"bad offset":    {url.Values{"offset": {"-1"}}, http.StatusBadRequest, 0, 0},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/users?"+tc.query.Encode(), nil)
+			rr := httptest.NewRecorder()
+			query.ServeSlice(rr, req, memorySchema, memoryUsers())
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, tc.code)
+
+			if tc.code != http.StatusOK {
+				return
+			}
+
+			data, _ := wrap.Data.(map[string]interface{})
+			items, _ := data["items"].([]interface{})
+			if data["total"] != tc.total || len(items) != tc.items {
+				t.Fatalf(`expected %d of %v items, got: '%v'`, tc.items, tc.total, wrap.Data)
+			}
+		})
+	}
+}
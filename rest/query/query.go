@@ -0,0 +1,139 @@
+// Package query parses the filter and sort query parameters of list endpoints.
+//
+//  GET /users?filter=status eq 'active' and (age ge 18 or role in ('admin','owner'))&sort=-created,name
+//
+// The filter expression will be parsed into an Expr and validated against a Schema of allowed
+// fields and operators. Query.Apply evaluates it in memory and Query.SQL translates it into
+// parameterized SQL, other backends implement Visitor. Problems are reported as *Error, which
+// rest.Error will render as http.StatusBadRequest containing the position of the offending token.
+//
+//  var schema = query.Schema{
+//      "name":    {Type: query.TypeString, Sortable: true},
+//      "age":     {Type: query.TypeNumber, Sortable: true},
+//      "created": {Type: query.TypeTime, Sortable: true, Operators: []query.Operator{query.OpGt, query.OpLt}},
+//  }
+//
+//  func list(w http.ResponseWriter, r *http.Request) {
+//      q, err := query.Parse(r, schema)
+//      if err != nil {
+//          rest.Error(w, r, err)
+//          return
+//      }
+//      ...
+//  }
+package query
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// Query parameters which will be parsed by Parse.
+const (
+	FilterParam = "filter"
+	SortParam   = "sort"
+	LimitParam  = "limit"
+	OffsetParam = "offset"
+)
+
+// DefaultLimit will be used by Parse if the request contains no LimitParam.
+var DefaultLimit = 20
+
+// MaxLimit is the maximum LimitParam accepted by Parse.
+var MaxLimit = 100
+
+// Query contains the parsed filter, sort and paging query parameters.
+type Query struct {
+	// Filter is nil if no filter has been requested.
+	Filter Expr
+	// Sort contains the requested order.
+	Sort []Sort
+	// Limit is the maximum number of items, it defaults to DefaultLimit.
+	Limit int
+	// Offset is the number of items to skip.
+	Offset int
+}
+
+// Parse will parse the FilterParam, SortParam, LimitParam and OffsetParam query parameters of r.
+func Parse(r *http.Request, schema Schema) (*Query, error) {
+	values := r.URL.Query()
+
+	filter, err := ParseFilter(values.Get(FilterParam), schema)
+	if err != nil {
+		return nil, err
+	}
+
+	sort, err := ParseSort(values.Get(SortParam), schema)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := parseInt(values.Get(LimitParam), LimitParam, DefaultLimit, 1, MaxLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := parseInt(values.Get(OffsetParam), OffsetParam, 0, 0, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Query{Filter: filter, Sort: sort, Limit: limit, Offset: offset}, nil
+}
+
+// parseInt parses the integer s of param between lower and upper, it defaults to def.
+func parseInt(s, param string, def, lower, upper int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil || i < lower {
+		return 0, &Error{Param: param, Position: 1, Token: s, Reason: "expected integer of at least " + strconv.Itoa(lower)}
+	}
+	if i > upper {
+		return 0, &Error{Param: param, Position: 1, Token: s, Reason: "exceeds maximum of " + strconv.Itoa(upper)}
+	}
+
+	return i, nil
+}
+
+// Error describes an invalid query parameter, e.g. a filter or sort expression.
+//
+// It implements wrapped.StatusCodeResponder, wrapped.MsgResponder and wrapped.DataResponder,
+// so rest.Error will respond with http.StatusBadRequest and the Error as Data.
+type Error struct {
+	// Param is the query parameter which contains the error.
+	Param string `json:"param"`
+	// Position is the 1-based position of Token in the parameter.
+	Position int `json:"position"`
+	// Token is the offending token, it is empty at the end of the expression.
+	Token string `json:"token,omitempty"`
+	// Reason describes the problem.
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	msg := e.Param + ": " + e.Reason
+	if e.Token != "" {
+		msg += " '" + e.Token + "'"
+	}
+
+	return msg + " at position " + strconv.Itoa(e.Position)
+}
+
+// StatusCode implements wrapped.StatusCodeResponder.
+func (e *Error) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Message implements wrapped.MsgResponder.
+func (e *Error) Message() string {
+	return e.Error()
+}
+
+// Data implements wrapped.DataResponder.
+func (e *Error) Data() interface{} {
+	return e
+}
@@ -0,0 +1,73 @@
+package query_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/rest/query"
+	"github.com/lanz-dev/go-rest/resttest"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	values := url.Values{}
+	values.Set(query.FilterParam, "name eq 'alice'")
+	values.Set(query.SortParam, "-created")
+	req := httptest.NewRequest("GET", "/users?"+values.Encode(), nil)
+
+	q, err := query.Parse(req, schema)
+	if err != nil {
+		t.Fatalf(`did not expect error, got: '%s'`, err)
+	}
+
+	if c, ok := q.Filter.(*query.Comparison); !ok || c.Field != "name" || c.Value() != "alice" {
+		t.Fatalf(`expected comparison of name, got: '%v'`, q.Filter)
+	}
+	if len(q.Sort) != 1 || q.Sort[0].Field != "created" || !q.Sort[0].Desc {
+		t.Fatalf(`expected sort by created desc, got: '%v'`, q.Sort)
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		query   url.Values
+		message string
+	}{
+		"filter": {url.Values{query.FilterParam: {"name eq 1"}}, "filter: expected string '1' at position 9"},
+		"sort":   {url.Values{query.SortParam: {"email"}}, "sort: unknown field 'email' at position 1"},
+		"end":    {url.Values{query.FilterParam: {"name eq"}}, "filter: expected string at position 8"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/users?"+tc.query.Encode(), nil)
+			rr := httptest.NewRecorder()
+
+			_, err := query.Parse(req, schema)
+			rest.Error(rr, req, err)
+
+			resp := rr.Result()
+			defer resp.Body.Close()
+			wrap := resttest.ParseToWrapped(t, resp.Body)
+			resttest.ExpectStatusCode(t, resp, wrap, http.StatusBadRequest)
+
+			if wrap.Message != tc.message {
+				t.Fatalf(`expected Message to be '%s', got: '%s'`, tc.message, wrap.Message)
+			}
+			data, ok := wrap.Data.(map[string]interface{})
+			if !ok || data["position"] == nil || data["reason"] == nil {
+				t.Fatalf(`expected error details in Data, got: '%v'`, wrap.Data)
+			}
+		})
+	}
+}
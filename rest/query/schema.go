@@ -0,0 +1,63 @@
+package query
+
+// Type is the type of a Field, it determines the valid values and the default operators.
+type Type int
+
+const (
+	// TypeString fields accept quoted strings, e.g. 'active'.
+	TypeString Type = iota
+	// TypeNumber fields accept integers and decimals, e.g. 18 or -1.5.
+	TypeNumber
+	// TypeBool fields accept true and false.
+	TypeBool
+	// TypeTime fields accept quoted RFC 3339 timestamps or dates, e.g. '2021-01-02'.
+	TypeTime
+)
+
+// Field declares a field which can be used in filter and sort expressions.
+type Field struct {
+	// Type is the type of the values.
+	Type Type
+	// Operators contains the allowed operators. If nil, all operators of Type are allowed.
+	Operators []Operator
+	// Sortable allows the field in sort expressions.
+	Sortable bool
+	// Column is the name of the field in the backend, e.g. the column in SQL or the field in Go.
+	// It defaults to the name of the Field.
+	Column string
+}
+
+// Schema declares the allowed fields by their name in expressions.
+type Schema map[string]Field
+
+var defaultOperators = map[Type][]Operator{
+	TypeString: {OpEq, OpNe, OpGt, OpGe, OpLt, OpLe, OpIn, OpLike},
+	TypeNumber: {OpEq, OpNe, OpGt, OpGe, OpLt, OpLe, OpIn},
+	TypeBool:   {OpEq, OpNe},
+	TypeTime:   {OpEq, OpNe, OpGt, OpGe, OpLt, OpLe},
+}
+
+// allows reports if op can be applied to f.
+func (f Field) allows(op Operator) bool {
+	operators := f.Operators
+	if operators == nil {
+		operators = defaultOperators[f.Type]
+	}
+
+	for _, allowed := range operators {
+		if allowed == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// column returns the Column of f or name.
+func (f Field) column(name string) string {
+	if f.Column != "" {
+		return f.Column
+	}
+
+	return name
+}
@@ -0,0 +1,55 @@
+package query
+
+import (
+	"strings"
+)
+
+// ParseSort will parse and validate the sort expression s, a comma separated list of fields.
+// Fields prefixed with "-" will be sorted descending.
+//
+//  -created,name
+//
+// Only fields which are Sortable in schema are allowed, each at most once.
+func ParseSort(s string, schema Schema) ([]Sort, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var sorts []Sort
+	seen := make(map[string]bool)
+
+	pos := 1
+	for _, part := range strings.Split(s, ",") {
+		start := pos + len(part) - len(strings.TrimLeft(part, " "))
+		name := strings.TrimSpace(part)
+		pos += len(part) + 1
+
+		desc := false
+		switch {
+		case strings.HasPrefix(name, "-"):
+			desc = true
+			name = name[1:]
+		case strings.HasPrefix(name, "+"):
+			name = name[1:]
+		}
+
+		if name == "" {
+			return nil, &Error{Param: SortParam, Position: start, Token: strings.TrimSpace(part), Reason: "expected field"}
+		}
+
+		field, ok := schema[name]
+		switch {
+		case !ok:
+			return nil, &Error{Param: SortParam, Position: start, Token: name, Reason: "unknown field"}
+		case !field.Sortable:
+			return nil, &Error{Param: SortParam, Position: start, Token: name, Reason: "field not sortable"}
+		case seen[name]:
+			return nil, &Error{Param: SortParam, Position: start, Token: name, Reason: "duplicate field"}
+		}
+
+		seen[name] = true
+		sorts = append(sorts, Sort{Field: name, Column: field.column(name), Desc: desc})
+	}
+
+	return sorts, nil
+}
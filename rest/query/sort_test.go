@@ -0,0 +1,80 @@
+package query_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/rest/query"
+)
+
+func TestParseSort(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sort string
+		want []query.Sort
+	}{
+		"empty": {"", nil},
+		"asc":   {"name", []query.Sort{{Field: "name", Column: "name"}}},
+		"mixed": {"-created, +age", []query.Sort{
+			{Field: "created", Column: "created", Desc: true},
+			{Field: "age", Column: "age_years"},
+		}},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := query.ParseSort(tc.sort, schema)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf(`expected '%v', got: '%v'`, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseSort_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sort     string
+		position int
+		token    string
+		reason   string
+	}{
+		"unknown field":  {"name,email", 6, "email", "unknown field"},
+		"not sortable":   {"-name, -score", 8, "score", "field not sortable"},
+		"duplicate":      {"name,-name", 6, "name", "duplicate field"},
+		"empty field":    {"name,,age", 6, "", "expected field"},
+		"only direction": {"-", 1, "-", "expected field"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := query.ParseSort(tc.sort, schema)
+
+			var queryErr *query.Error
+			if !errors.As(err, &queryErr) {
+				t.Fatalf(`expected query.Error, got: '%v'`, err)
+			}
+			if queryErr.Param != query.SortParam {
+				t.Fatalf(`expected Param to be '%s', got: '%s'`, query.SortParam, queryErr.Param)
+			}
+			if queryErr.Position != tc.position || queryErr.Token != tc.token || queryErr.Reason != tc.reason {
+				t.Fatalf(`expected error '%s' at %d with '%s', got: '%s' at %d with '%s'`,
+					tc.reason, tc.position, tc.token, queryErr.Reason, queryErr.Position, queryErr.Token)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect determines the placeholders and identifier quoting of generated SQL.
+type Dialect int
+
+// Supported SQL dialects.
+const (
+	// Postgres uses numbered placeholders $1, $2, ... and double quoted identifiers.
+	Postgres Dialect = iota
+	// MySQL uses ? placeholders and backtick quoted identifiers.
+	MySQL
+	// SQLite uses ? placeholders and double quoted identifiers.
+	SQLite
+)
+
+// SQL contains parameterized SQL fragments of a Query, see Query.SQL.
+//
+// The fragments do not contain the keywords WHERE, ORDER BY and LIMIT, so they can be combined
+// with conditions of the handler. User input is only contained in Args.
+type SQL struct {
+	// Where is the condition of the filter enclosed in parentheses, so it can be combined with
+	// other conditions by AND. It is empty if no filter has been requested.
+	Where string
+	// OrderBy is the comma separated order, it is empty if no sort has been requested.
+	OrderBy string
+	// Limit contains the placeholders of the limit and offset, e.g. "$3 OFFSET $4".
+	// It is empty if the Query has no Limit.
+	Limit string
+	// Args contains the arguments of the placeholders in order.
+	Args []interface{}
+}
+
+// SQL will translate q into parameterized SQL fragments of dialect d.
+//
+// Args will be prepended to the arguments, so numbered placeholders continue after them.
+// Columns of the Schema are used as identifiers and must be plain names, optionally qualified
+// by a table, e.g. "users.created_at". Other columns will be rejected with an error.
+//
+// The conditions match the same items as Query.Apply, e.g. "ne" matches null values. Only
+// "like" differs: it is case-sensitive in Query.Apply, but depends on the collation of the
+// column in SQL, e.g. it is case-insensitive for most collations of MySQL.
+//
+//  s, err := q.SQL(query.Postgres, tenantID)
+//  if err != nil {
+//      rest.Error(w, r, err)
+//      return
+//  }
+//  where := "tenant_id = $1"
+//  if s.Where != "" {
+//      where += " AND " + s.Where
+//  }
+//  stmt := "SELECT id, name FROM users WHERE " + where
+//  if s.OrderBy != "" {
+//      stmt += " ORDER BY " + s.OrderBy
+//  }
+//  if s.Limit != "" {
+//      stmt += " LIMIT " + s.Limit
+//  }
+//  rows, err := db.QueryContext(r.Context(), stmt, s.Args...)
+func (q *Query) SQL(d Dialect, args ...interface{}) (*SQL, error) {
+	if d != Postgres && d != MySQL && d != SQLite {
+		return nil, fmt.Errorf("query: unknown dialect %d", d)
+	}
+
+	b := &sqlBuilder{dialect: d, args: args}
+
+	s := &SQL{}
+	if q.Filter != nil {
+		if err := q.Filter.Accept(b); err != nil {
+			return nil, err
+		}
+		s.Where = "(" + b.sql.String() + ")"
+	}
+
+	order := make([]string, 0, len(q.Sort))
+	for _, sort := range q.Sort {
+		column, err := b.quote(sort.Column)
+		if err != nil {
+			return nil, err
+		}
+		if sort.Desc {
+			order = append(order, column+" DESC")
+		} else {
+			order = append(order, column+" ASC")
+		}
+	}
+	s.OrderBy = strings.Join(order, ", ")
+
+	if q.Limit > 0 {
+		s.Limit = b.placeholder(q.Limit) + " OFFSET " + b.placeholder(q.Offset)
+	}
+
+	s.Args = b.args
+	return s, nil
+}
+
+// sqlColumn matches the allowed columns, optionally qualified identifiers.
+var sqlColumn = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+var sqlOperators = map[Operator]string{
+	OpEq:   "=",
+	OpNe:   "<>",
+	OpGt:   ">",
+	OpGe:   ">=",
+	OpLt:   "<",
+	OpLe:   "<=",
+	OpLike: "LIKE",
+}
+
+// sqlBuilder is a Visitor which writes the SQL condition of an Expr.
+type sqlBuilder struct {
+	dialect Dialect
+	sql     strings.Builder
+	args    []interface{}
+}
+
+// placeholder adds arg and returns its placeholder.
+func (b *sqlBuilder) placeholder(arg interface{}) string {
+	b.args = append(b.args, arg)
+	if b.dialect == Postgres {
+		return "$" + strconv.Itoa(len(b.args))
+	}
+
+	return "?"
+}
+
+// quote validates and quotes the identifier column.
+func (b *sqlBuilder) quote(column string) (string, error) {
+	if !sqlColumn.MatchString(column) {
+		return "", fmt.Errorf("query: invalid column %q", column)
+	}
+
+	q := `"`
+	if b.dialect == MySQL {
+		q = "`"
+	}
+
+	return q + strings.ReplaceAll(column, ".", q+"."+q) + q, nil
+}
+
+func (b *sqlBuilder) VisitLogical(e *Logical) error {
+	for i, operand := range e.Operands {
+		if i > 0 {
+			b.sql.WriteString(" " + strings.ToUpper(string(e.Op)) + " ")
+		}
+
+		_, nested := operand.(*Logical)
+		if nested {
+			b.sql.WriteString("(")
+		}
+		if err := operand.Accept(b); err != nil {
+			return err
+		}
+		if nested {
+			b.sql.WriteString(")")
+		}
+	}
+
+	return nil
+}
+
+func (b *sqlBuilder) VisitComparison(e *Comparison) error {
+	column, err := b.quote(e.Column)
+	if err != nil {
+		return err
+	}
+
+	if e.Op == OpIn {
+		placeholders := make([]string, 0, len(e.Values))
+		for _, value := range e.Values {
+			placeholders = append(placeholders, b.placeholder(value))
+		}
+		b.sql.WriteString(column + " IN (" + strings.Join(placeholders, ", ") + ")")
+		return nil
+	}
+
+	op, ok := sqlOperators[e.Op]
+	if !ok {
+		return fmt.Errorf("query: unsupported operator %q", e.Op)
+	}
+
+	value := e.Value()
+	if value == nil {
+		switch e.Op {
+		case OpEq:
+			b.sql.WriteString(column + " IS NULL")
+		case OpNe:
+			b.sql.WriteString(column + " IS NOT NULL")
+		default:
+			return fmt.Errorf("query: operator %q does not support null", e.Op)
+		}
+		return nil
+	}
+
+	if e.Op == OpNe {
+		// ne matches null like Query.Apply, but <> alone would drop NULL rows.
+		b.sql.WriteString("(" + column + " <> " + b.placeholder(value) + " OR " + column + " IS NULL)")
+		return nil
+	}
+
+	b.sql.WriteString(column + " " + op + " " + b.placeholder(value))
+	return nil
+}
@@ -0,0 +1,207 @@
+package query_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest/query"
+)
+
+var sqlSchema = query.Schema{
+	"name":    {Type: query.TypeString, Sortable: true},
+	"status":  {Type: query.TypeString, Column: "users.status"},
+	"age":     {Type: query.TypeNumber, Sortable: true},
+	"active":  {Type: query.TypeBool},
+	"created": {Type: query.TypeTime, Sortable: true, Column: "created_at"},
+}
+
+func TestQuery_SQL(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		filter  string
+		sort    string
+		limit   int
+		offset  int
+		dialect query.Dialect
+		args    []interface{}
+		want    query.SQL
+	}{
+		"empty": {dialect: query.Postgres, want: query.SQL{}},
+		"postgres": {
+			filter: "name eq 'alice' and age ge 18", sort: "-created,name", limit: 10, offset: 20, dialect: query.Postgres,
+			want: query.SQL{
+				Where:   `("name" = $1 AND "age" >= $2)`,
+				OrderBy: `"created_at" DESC, "name" ASC`,
+				Limit:   "$3 OFFSET $4",
+				Args:    []interface{}{"alice", int64(18), 10, 20},
+			},
+		},
+		"mysql": {
+			filter: "name like 'a%' or status in ('active', 'invited')", sort: "age", limit: 5, dialect: query.MySQL,
+			want: query.SQL{
+				Where:   "(`name` LIKE ? OR `users`.`status` IN (?, ?))",
+				OrderBy: "`age` ASC",
+				Limit:   "? OFFSET ?",
+				Args:    []interface{}{"a%", "active", "invited", 5, 0},
+			},
+		},
+		"sqlite": {
+			filter: "active eq true and created lt '2021-01-02'", dialect: query.SQLite,
+			want: query.SQL{
+				Where: `("active" = ? AND "created_at" < ?)`,
+				Args:  []interface{}{true, created},
+			},
+		},
+		"nested": {
+			filter: "(name eq 'a' or name eq 'b') and (age lt 18 or age gt 65)", dialect: query.Postgres,
+			want: query.SQL{
+				Where: `(("name" = $1 OR "name" = $2) AND ("age" < $3 OR "age" > $4))`,
+				Args:  []interface{}{"a", "b", int64(18), int64(65)},
+			},
+		},
+		"null": {
+			filter: "name eq null or status ne null", dialect: query.Postgres,
+			want: query.SQL{Where: `("name" IS NULL OR "users"."status" IS NOT NULL)`},
+		},
+		"injection": {
+			filter: "name eq 'x''; DROP TABLE users; --'", dialect: query.Postgres,
+			want: query.SQL{Where: `("name" = $1)`, Args: []interface{}{"x'; DROP TABLE users; --"}},
+		},
+		"args": {
+			filter: "age ne 1", limit: 1, dialect: query.Postgres, args: []interface{}{"tenant"},
+			want: query.SQL{
+				Where: `(("age" <> $2 OR "age" IS NULL))`,
+				Limit: "$3 OFFSET $4",
+				Args:  []interface{}{"tenant", int64(1), 1, 0},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := query.ParseFilter(tc.filter, sqlSchema)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			sort, err := query.ParseSort(tc.sort, sqlSchema)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+
+			q := &query.Query{Filter: filter, Sort: sort, Limit: tc.limit, Offset: tc.offset}
+			got, err := q.SQL(tc.dialect, tc.args...)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Fatalf(`expected '%#v', got: '%#v'`, tc.want, *got)
+			}
+		})
+	}
+}
+
+// TestQuery_Backends runs the same filters with Query.Apply and Query.SQL, so both backends
+// share the semantics of null values.
+func TestQuery_Backends(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		filter string
+		names  []string
+		where  string
+		args   []interface{}
+	}{
+		"ne":       {"owner.id ne 1", []string{"bob", "carol"}, `(("owner"."id" <> $1 OR "owner"."id" IS NULL))`, []interface{}{int64(1)}},
+		"ne null":  {"owner.id ne null", []string{"alice", "carol", "dave"}, `("owner"."id" IS NOT NULL)`, nil},
+		"eq null":  {"owner.id eq null", []string{"bob"}, `("owner"."id" IS NULL)`, nil},
+		"gt":       {"owner.id gt 1", []string{"carol"}, `("owner"."id" > $1)`, []interface{}{int64(1)}},
+		"ne or eq": {"status ne 'active' or owner.id eq 2", []string{"bob", "carol", "dave"}, `(("status" <> $1 OR "status" IS NULL) OR "owner"."id" = $2)`, []interface{}{"active", int64(2)}},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := query.ParseFilter(tc.filter, memorySchema)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			q := &query.Query{Filter: filter}
+
+			list, err := q.Apply(memoryUsers())
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			if got := names(t, list); !reflect.DeepEqual(got, tc.names) {
+				t.Fatalf(`expected memory to match '%v', got: '%v'`, tc.names, got)
+			}
+
+			s, err := q.SQL(query.Postgres)
+			if err != nil {
+				t.Fatalf(`did not expect error, got: '%s'`, err)
+			}
+			if s.Where != tc.where || !reflect.DeepEqual(s.Args, tc.args) {
+				t.Fatalf(`expected SQL '%s' %v, got: '%s' %v`, tc.where, tc.args, s.Where, s.Args)
+			}
+		})
+	}
+}
+
+func TestQuery_SQL_CallerCondition(t *testing.T) {
+	t.Parallel()
+
+	filter, err := query.ParseFilter("name eq 'alice' or name ne 'bob'", sqlSchema)
+	if err != nil {
+		t.Fatalf(`did not expect error, got: '%s'`, err)
+	}
+
+	q := &query.Query{Filter: filter}
+	s, err := q.SQL(query.Postgres, "tenant")
+	if err != nil {
+		t.Fatalf(`did not expect error, got: '%s'`, err)
+	}
+
+	where := "tenant_id = $1 AND " + s.Where
+	if want := `tenant_id = $1 AND ("name" = $2 OR ("name" <> $3 OR "name" IS NULL))`; where != want {
+		t.Fatalf(`expected '%s', got: '%s'`, want, where)
+	}
+}
+
+func TestQuery_SQL_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		q       *query.Query
+		dialect query.Dialect
+	}{
+		"dialect": {&query.Query{}, query.Dialect(42)},
+		"filter column": {&query.Query{
+			Filter: &query.Comparison{Column: "name; DROP TABLE users", Op: query.OpEq, Values: []interface{}{"a"}},
+		}, query.Postgres},
+		"sort column": {&query.Query{Sort: []query.Sort{{Column: `name"`}}}, query.MySQL},
+		"null":        {&query.Query{Filter: &query.Comparison{Column: "age", Op: query.OpGt, Values: []interface{}{nil}}}, query.SQLite},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := tc.q.SQL(tc.dialect); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
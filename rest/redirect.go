@@ -0,0 +1,168 @@
+package rest
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// Redirect will be set as Data on the wrapped.Response of the redirect helpers, so API
+// clients which don't follow redirects can read the target.
+type Redirect struct {
+	// Location contains the resolved target, it equals the Location header.
+	Location string `json:"location"`
+}
+
+// RedirectError will be rendered by the redirect helpers if the target is not allowed.
+//
+// It implements wrapped.StatusCodeResponder, so rest.Error will respond with http.StatusBadRequest.
+type RedirectError struct {
+	Target string
+}
+
+func (e *RedirectError) Error() string {
+	return "redirect target not allowed: " + e.Target
+}
+
+// StatusCode implements wrapped.StatusCodeResponder.
+func (e *RedirectError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// RedirectOption configures the redirect helpers.
+type RedirectOption func(*redirectOptions)
+
+type redirectOptions struct {
+	hosts []string
+}
+
+// WithAllowedHosts allows absolute targets on hosts. A host can start with "*." to allow all
+// subdomains, e.g. "*.example.com".
+//
+// Relative targets and targets on the host of the request are always allowed.
+func WithAllowedHosts(hosts ...string) RedirectOption {
+	return func(o *redirectOptions) {
+		o.hosts = append(o.hosts, hosts...)
+	}
+}
+
+// 3xx
+
+// MovedPermanently The URL of the requested resource has been changed permanently.
+// The new URL is given in the response.
+func MovedPermanently(w http.ResponseWriter, r *http.Request, target string, opts ...RedirectOption) {
+	redirect(w, r, http.StatusMovedPermanently, target, opts)
+}
+
+// Found This response code means that the URI of requested resource has been changed
+// temporarily. Further changes in the URI might be made in the future. Therefore, this
+// same URI should be used by the client in future requests.
+func Found(w http.ResponseWriter, r *http.Request, target string, opts ...RedirectOption) {
+	redirect(w, r, http.StatusFound, target, opts)
+}
+
+// SeeOther The server sent this response to direct the client to get the requested
+// resource at another URI with a GET request.
+func SeeOther(w http.ResponseWriter, r *http.Request, target string, opts ...RedirectOption) {
+	redirect(w, r, http.StatusSeeOther, target, opts)
+}
+
+// TemporaryRedirect The server sends this response to direct the client to get the
+// requested resource at another URI with same method that was used in the prior request.
+// This has the same semantics as the 302 Found HTTP response code, with the exception
+// that the user agent must not change the HTTP method used.
+func TemporaryRedirect(w http.ResponseWriter, r *http.Request, target string, opts ...RedirectOption) {
+	redirect(w, r, http.StatusTemporaryRedirect, target, opts)
+}
+
+// PermanentRedirect This means that the resource is now permanently located at another
+// URI, specified by the Location: HTTP Response header. This has the same semantics as
+// the 301 Moved Permanently HTTP response code, with the exception that the user agent
+// must not change the HTTP method used.
+func PermanentRedirect(w http.ResponseWriter, r *http.Request, target string, opts ...RedirectOption) {
+	redirect(w, r, http.StatusPermanentRedirect, target, opts)
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, code int, target string, opts []RedirectOption) {
+	var o redirectOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	location, err := resolveRedirect(r, target, o.hosts)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", location)
+	Render(w, r, &wrapped.Response{Code: code, Data: Redirect{Location: location}})
+}
+
+// resolveRedirect resolves target relative to the request and validates it against hosts.
+// Paths will be prefixed with the base path of BaseURL like ResolveURL does.
+func resolveRedirect(r *http.Request, target string, hosts []string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil || strings.Contains(target, "\\") {
+		return "", &RedirectError{Target: target}
+	}
+
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return "", &RedirectError{Target: target}
+	}
+
+	if u.Host != "" && !strings.EqualFold(u.Host, r.Host) && !hostAllowed(u.Hostname(), hosts) {
+		return "", &RedirectError{Target: target}
+	}
+	if u.Host == "" && u.Scheme != "" {
+		return "", &RedirectError{Target: target}
+	}
+
+	if u.Host != "" {
+		if u.Scheme == "" {
+			u.Scheme = "http"
+			if r.TLS != nil {
+				u.Scheme = "https"
+			}
+		}
+		return u.String(), nil
+	}
+
+	// Dot-segments are removed while resolving, e.g. "/.//evil.com" results in "//evil.com",
+	// so the result must be checked again.
+	location := (&url.URL{Path: r.URL.Path}).ResolveReference(u).String()
+	resolved, err := url.Parse(location)
+	if err != nil || resolved.Host != "" || strings.HasPrefix(resolved.Path, "//") || strings.HasPrefix(location, "//") {
+		return "", &RedirectError{Target: target}
+	}
+
+	// Paths are relative to the base path of ResolveURL, e.g. behind a proxy with X-Forwarded-Prefix.
+	if base := BaseURL(r); base.Path != "" {
+		trailingSlash := strings.HasSuffix(resolved.Path, "/")
+		resolved.Path = path.Join(base.Path, resolved.Path)
+		if trailingSlash && !strings.HasSuffix(resolved.Path, "/") {
+			resolved.Path += "/"
+		}
+		location = resolved.String()
+	}
+
+	return location, nil
+}
+
+func hostAllowed(host string, hosts []string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed {
+			return true
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+
+	return false
+}
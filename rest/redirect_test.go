@@ -0,0 +1,144 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+func TestRedirectHelper(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		code    int
+		handler func(w http.ResponseWriter, r *http.Request, target string, opts ...rest.RedirectOption)
+	}{
+		"moved permanently":  {http.StatusMovedPermanently, rest.MovedPermanently},
+		"found":              {http.StatusFound, rest.Found},
+		"see other":          {http.StatusSeeOther, rest.SeeOther},
+		"temporary redirect": {http.StatusTemporaryRedirect, rest.TemporaryRedirect},
+		"permanent redirect": {http.StatusPermanentRedirect, rest.PermanentRedirect},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/api/users/1", nil)
+			rr := httptest.NewRecorder()
+			tc.handler(rr, req, "/api/accounts/1")
+			res := parseBodyToResponse(t, rr.Body)
+
+			if rr.Code != tc.code || res.Code != tc.code {
+				t.Fatalf(`expected Code to be '%d', got: '%d'`, tc.code, rr.Code)
+			}
+			if res.Status != wrapped.StatusSuccess {
+				t.Fatalf(`expected Status to be '%s', got: '%s'`, wrapped.StatusSuccess, res.Status)
+			}
+			if location := rr.Header().Get("Location"); location != "/api/accounts/1" {
+				t.Fatalf(`expected Location to be '%s', got: '%s'`, "/api/accounts/1", location)
+			}
+			data, ok := res.Data.(map[string]interface{})
+			if !ok || data["location"] != "/api/accounts/1" {
+				t.Fatalf(`expected location in Data, got: '%v'`, res.Data)
+			}
+		})
+	}
+}
+
+func TestRedirectHelper_Target(t *testing.T) {
+	t.Parallel()
+
+	allowed := rest.WithAllowedHosts("auth.example.com", "*.cdn.example.com")
+
+	tests := map[string]struct {
+		target   string
+		opts     []rest.RedirectOption
+		code     int
+		location string
+	}{
+		"absolute path":        {"/login", nil, http.StatusFound, "/login"},
+		"relative path":        {"2", nil, http.StatusFound, "/api/users/2"},
+		"parent path":          {"../groups", nil, http.StatusFound, "/api/groups"},
+		"query":                {"?page=2", nil, http.StatusFound, "/api/users/1?page=2"},
+		"same host":            {"https://example.com/login", nil, http.StatusFound, "https://example.com/login"},
+		"allowed host":         {"https://auth.example.com/login", []rest.RedirectOption{allowed}, http.StatusFound, "https://auth.example.com/login"},
+		"allowed subdomain":    {"https://eu.cdn.example.com/a.png", []rest.RedirectOption{allowed}, http.StatusFound, "https://eu.cdn.example.com/a.png"},
+		"foreign host":         {"https://evil.com/login", []rest.RedirectOption{allowed}, http.StatusBadRequest, ""},
+		"protocol relative":    {"//evil.com/login", nil, http.StatusBadRequest, ""},
+		"backslash":            {"/\\evil.com", nil, http.StatusBadRequest, ""},
+		"dot segment":          {"/.//evil.com", []rest.RedirectOption{allowed}, http.StatusBadRequest, ""},
+		"dot dot segment":      {"/a/..//evil.com", []rest.RedirectOption{allowed}, http.StatusFound, "/evil.com"},
+		"relative dot segment": {".//evil.com", nil, http.StatusFound, "/api/users//evil.com"},
+		"javascript":           {"javascript:alert(1)", nil, http.StatusBadRequest, ""},
+		"subdomain suffix":     {"https://evilcdn.example.com", []rest.RedirectOption{allowed}, http.StatusBadRequest, ""},
+		"allowed without path": {"//auth.example.com", []rest.RedirectOption{allowed}, http.StatusFound, "http://auth.example.com"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/api/users/1", nil)
+			req.Host = "example.com"
+			rr := httptest.NewRecorder()
+			rest.Found(rr, req, tc.target, tc.opts...)
+			res := parseBodyToResponse(t, rr.Body)
+
+			if res.Code != tc.code {
+				t.Fatalf(`expected Code to be '%d', got: '%d'`, tc.code, res.Code)
+			}
+			if location := rr.Header().Get("Location"); location != tc.location {
+				t.Fatalf(`expected Location to be '%s', got: '%s'`, tc.location, location)
+			}
+		})
+	}
+}
+
+func TestFound_ForwardedPrefix(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		target   string
+		location string
+	}{
+		"absolute path": {"/login", "/v1/login"},
+		"relative path": {"2", "/v1/api/users/2"},
+		"parent path":   {"../groups/", "/v1/api/groups/"},
+		"query":         {"?page=2", "/v1/api/users/1?page=2"},
+		"dot segment":   {"/a/..//evil.com", "/v1/evil.com"},
+		"absolute url":  {"https://example.com/login", "https://example.com/login"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/api/users/1", nil)
+			req.Host = "example.com"
+			req.Header.Set("X-Forwarded-Prefix", "/v1/")
+			rr := httptest.NewRecorder()
+			rest.Found(rr, req, tc.target)
+
+			if rr.Code != http.StatusFound {
+				t.Fatalf(`expected Code to be '%d', got: '%d'`, http.StatusFound, rr.Code)
+			}
+			if location := rr.Header().Get("Location"); location != tc.location {
+				t.Fatalf(`expected Location to be '%s', got: '%s'`, tc.location, location)
+			}
+			if want := rest.ResolveURL(req, tc.target); !strings.HasSuffix(want, tc.location) {
+				t.Fatalf(`expected Location to match ResolveURL '%s', got: '%s'`, want, tc.location)
+			}
+		})
+	}
+}
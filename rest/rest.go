@@ -16,17 +16,52 @@
 var MarshalFn = json.Marshal
 
 // Render will call Render on wrapped.Response to prepare the response and json.Marshal it to w.
+//
+// If the Accept header of the request prefers a media type of Encoders (e.g. MediaTypeHAL),
+// the registered EncoderFn will be used instead of MarshalFn.
+//
+// If SparseFieldsFromCtx is true, Data of JSON responses will be pruned to the fields of
+// FieldsParam, see SelectFields.
 func Render(w http.ResponseWriter, r *http.Request, wrapped *wrapped.Response) {
 	wrapped.Parse(r.Context())
 
-	data, err := MarshalFn(wrapped)
+	mediaType := negotiate(r)
+	encode, ok := Encoders[mediaType]
+	if !ok {
+		mediaType = MediaTypeJSON
+		encode = marshal
+	}
+
+	if mediaType == MediaTypeJSON && wrapped.Code < http.StatusBadRequest && SparseFieldsFromCtx(r.Context()) {
+		data, err := SelectFields(wrapped.Data, fieldsFromRequest(r))
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+		wrapped.Data = data
+	}
+
+	data, err := encode(r, wrapped)
 	if err != nil {
 		Error(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	contentType := mediaType + "; charset=utf-8"
+	if mediaType == MediaTypeJSONAPI {
+		// JSON:API forbids media type parameters except ext and profile.
+		contentType = mediaType
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if len(Encoders) > 0 {
+		w.Header().Add("Vary", "Accept")
+	}
 	w.WriteHeader(wrapped.Code)
 
 	_, _ = w.Write(data)
 }
+
+func marshal(_ *http.Request, res *wrapped.Response) ([]byte, error) {
+	return MarshalFn(res)
+}
@@ -6,12 +6,20 @@
 	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/lanz-dev/go-rest/rest"
 	"github.com/lanz-dev/go-rest/wrapped"
 )
 
+func TestMain(m *testing.M) {
+	rest.Encoders[rest.MediaTypeHAL] = rest.MarshalHAL
+	rest.Encoders[rest.MediaTypeJSONAPI] = rest.MarshalJSONAPI
+
+	os.Exit(m.Run())
+}
+
 func parseBodyToResponse(t *testing.T, r io.Reader) wrapped.Response {
 	var res wrapped.Response
 	if err := json.NewDecoder(r).Decode(&res); err != nil {
@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+// Retry will be set as Data on a wrapped.Response if a Retry-After header is sent.
+type Retry struct {
+	// After contains the delay in seconds after which the request could be retried.
+	After int `json:"retryAfter"`
+	// At contains the time after which the request could be retried.
+	At time.Time `json:"retryAt"`
+}
+
+// RateLimit describes the current state of a rate limit.
+//
+// It will be sent as RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset header, see
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/.
+type RateLimit struct {
+	// Limit is the request quota in the time window.
+	Limit int
+	// Remaining is the remaining quota in the current time window.
+	Remaining int
+	// Reset is the time until the quota resets.
+	Reset time.Duration
+}
+
+// SetRetryAfter will set the Retry-After header as delay-seconds and returns the matching Retry.
+func SetRetryAfter(w http.ResponseWriter, after time.Duration) Retry {
+	seconds := durationToSeconds(after)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	return Retry{After: seconds, At: time.Now().Add(time.Duration(seconds) * time.Second).UTC().Truncate(time.Second)}
+}
+
+// SetRetryAt will set the Retry-After header as HTTP-date and returns the matching Retry.
+func SetRetryAt(w http.ResponseWriter, at time.Time) Retry {
+	at = at.UTC().Truncate(time.Second)
+	w.Header().Set("Retry-After", at.Format(http.TimeFormat))
+
+	return Retry{After: durationToSeconds(time.Until(at)), At: at}
+}
+
+// SetRateLimit will set the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset header.
+func SetRateLimit(w http.ResponseWriter, limit RateLimit) {
+	remaining := limit.Remaining
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(durationToSeconds(limit.Reset)))
+}
+
+// TooManyRequestsRetryAfter is like TooManyRequests, but it will set the Retry-After header
+// as delay-seconds and Data to Retry.
+func TooManyRequestsRetryAfter(w http.ResponseWriter, r *http.Request, msg string, after time.Duration) {
+	retry := SetRetryAfter(w, after)
+	Render(w, r, &wrapped.Response{Code: http.StatusTooManyRequests, Message: msg, Data: retry})
+}
+
+// TooManyRequestsRetryAt is like TooManyRequests, but it will set the Retry-After header
+// as HTTP-date and Data to Retry.
+func TooManyRequestsRetryAt(w http.ResponseWriter, r *http.Request, msg string, at time.Time) {
+	retry := SetRetryAt(w, at)
+	Render(w, r, &wrapped.Response{Code: http.StatusTooManyRequests, Message: msg, Data: retry})
+}
+
+// TooManyRequestsRateLimit is like TooManyRequests, but it will set the RateLimit headers.
+// Retry-After and Data will be set to the time until the quota resets.
+func TooManyRequestsRateLimit(w http.ResponseWriter, r *http.Request, msg string, limit RateLimit) {
+	SetRateLimit(w, limit)
+	TooManyRequestsRetryAfter(w, r, msg, limit.Reset)
+}
+
+// ServiceUnavailableRetryAfter is like ServiceUnavailable, but it will set the Retry-After header
+// as delay-seconds and Data to Retry.
+func ServiceUnavailableRetryAfter(w http.ResponseWriter, r *http.Request, msg string, after time.Duration) {
+	retry := SetRetryAfter(w, after)
+	Render(w, r, &wrapped.Response{Code: http.StatusServiceUnavailable, Message: msg, Data: retry})
+}
+
+// ServiceUnavailableRetryAt is like ServiceUnavailable, but it will set the Retry-After header
+// as HTTP-date and Data to Retry.
+func ServiceUnavailableRetryAt(w http.ResponseWriter, r *http.Request, msg string, at time.Time) {
+	retry := SetRetryAt(w, at)
+	Render(w, r, &wrapped.Response{Code: http.StatusServiceUnavailable, Message: msg, Data: retry})
+}
+
+// durationToSeconds will round d up to full seconds.
+func durationToSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+
+	return int(math.Ceil(d.Seconds()))
+}
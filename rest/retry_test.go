@@ -0,0 +1,116 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lanz-dev/go-rest/rest"
+	"github.com/lanz-dev/go-rest/wrapped"
+)
+
+func TestRetryHelper(t *testing.T) {
+	t.Parallel()
+
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := map[string]struct {
+		code       int
+		status     string
+		retryAfter string
+		handler    http.HandlerFunc
+	}{
+		"too many requests retry after": {
+			http.StatusTooManyRequests, wrapped.StatusError, "120",
+			func(w http.ResponseWriter, r *http.Request) {
+				rest.TooManyRequestsRetryAfter(w, r, "msg", 2*time.Minute)
+			},
+		},
+		"too many requests retry after rounds up": {
+			http.StatusTooManyRequests, wrapped.StatusError, "2",
+			func(w http.ResponseWriter, r *http.Request) {
+				rest.TooManyRequestsRetryAfter(w, r, "msg", 1500*time.Millisecond)
+			},
+		},
+		"too many requests retry at": {
+			http.StatusTooManyRequests, wrapped.StatusError, at.Format(http.TimeFormat),
+			func(w http.ResponseWriter, r *http.Request) {
+				rest.TooManyRequestsRetryAt(w, r, "msg", at)
+			},
+		},
+		"too many requests rate limit": {
+			http.StatusTooManyRequests, wrapped.StatusError, "30",
+			func(w http.ResponseWriter, r *http.Request) {
+				rest.TooManyRequestsRateLimit(w, r, "msg", rest.RateLimit{Limit: 10, Remaining: 0, Reset: 30 * time.Second})
+			},
+		},
+		"service unavailable retry after": {
+			http.StatusServiceUnavailable, wrapped.StatusFail, "60",
+			func(w http.ResponseWriter, r *http.Request) {
+				rest.ServiceUnavailableRetryAfter(w, r, "msg", time.Minute)
+			},
+		},
+		"service unavailable retry at": {
+			http.StatusServiceUnavailable, wrapped.StatusFail, at.Format(http.TimeFormat),
+			func(w http.ResponseWriter, r *http.Request) {
+				rest.ServiceUnavailableRetryAt(w, r, "msg", at)
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/", nil)
+			rr := httptest.NewRecorder()
+			tc.handler(rr, req)
+			res := parseBodyToResponse(t, rr.Body)
+
+			if res.Code != tc.code {
+				t.Fatalf(`expected Code to be '%d', got: '%d'`, tc.code, res.Code)
+			}
+			if res.Status != tc.status {
+				t.Fatalf(`expected Status to be '%s', got: '%s'`, tc.status, res.Status)
+			}
+			if res.Message != "msg" {
+				t.Fatalf(`expected Message to be '%s', got: '%s'`, "msg", res.Message)
+			}
+			if retryAfter := rr.Header().Get("Retry-After"); retryAfter != tc.retryAfter {
+				t.Fatalf(`expected Retry-After to be '%s', got: '%s'`, tc.retryAfter, retryAfter)
+			}
+
+			data, ok := res.Data.(map[string]interface{})
+			if !ok {
+				t.Fatalf(`expected Data to be an object, got: '%+v'`, res.Data)
+			}
+			if _, ok := data["retryAfter"]; !ok {
+				t.Fatal(`expected Data to contain retryAfter`)
+			}
+			if _, ok := data["retryAt"]; !ok {
+				t.Fatal(`expected Data to contain retryAt`)
+			}
+		})
+	}
+}
+
+func TestSetRateLimit(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	rest.SetRateLimit(rr, rest.RateLimit{Limit: 100, Remaining: -1, Reset: 1500 * time.Millisecond})
+
+	expected := map[string]string{
+		"RateLimit-Limit":     "100",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "2",
+	}
+	for header, value := range expected {
+		if got := rr.Header().Get(header); got != value {
+			t.Fatalf(`expected %s to be '%s', got: '%s'`, header, value, got)
+		}
+	}
+}
@@ -47,10 +47,24 @@ type Response struct {
 	Message string `json:"message,omitempty"`
 	// Data can contain user provides data,
 	Data interface{} `json:"data,omitempty"`
+	// Links contains optional hypermedia links (HATEOAS) to related resources and actions.
+	Links []Link `json:"links,omitempty"`
 	// Err contains the error
 	Err error `json:"-"`
 }
 
+// Link is a hypermedia link of a Response.
+type Link struct {
+	// Rel describes the relation to the Response, e.g. "self" or "next".
+	Rel string `json:"rel"`
+	// Href contains the URL of the linked resource.
+	Href string `json:"href"`
+	// Method contains the HTTP method of an action, it will be omitted for GET.
+	Method string `json:"method,omitempty"`
+	// Title contains a human readable description.
+	Title string `json:"title,omitempty"`
+}
+
 func (res *Response) setCode() {
 	if res.Code != 0 {
 		return
@@ -140,5 +154,6 @@ func (res *Response) Reset() {
 	res.Status = ""
 	res.Message = ""
 	res.Data = nil
+	res.Links = nil
 	res.Err = nil
 }
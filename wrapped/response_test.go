@@ -276,6 +276,7 @@ func TestWrapped_Reset(t *testing.T) {
 		Status:  wrapped.StatusError,
 		Message: "msg",
 		Data:    "data",
+		Links:   []wrapped.Link{{Rel: "self", Href: "/unittest"}},
 		Err:     errors.New("unittest"),
 	}
 	res.Reset()
@@ -292,6 +293,9 @@ func TestWrapped_Reset(t *testing.T) {
 	if res.Data != nil {
 		t.Fatalf(`expected Data to be 'nil', got: '%s'`, res.Data)
 	}
+	if res.Links != nil {
+		t.Fatalf(`expected Links to be 'nil', got: '%v'`, res.Links)
+	}
 	if res.Err != nil {
 		t.Fatalf(`expected Err to be 'nil', got: '%s'`, res.Err)
 	}